@@ -0,0 +1,188 @@
+package docparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Severity of a Diagnostic.
+type Severity string
+
+// Severities; these are also the SARIF levels.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// codeParseError is the code for errors from Parse.
+const codeParseError = "parse-error"
+
+// Diagnostic is a problem in the source code, found while parsing or by Lint.
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Code     string // Rule ID: code of the LintRule, or "parse-error".
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", relFile(d.Pos.Filename), d.Pos.Line, d.Pos.Column,
+		d.Code, d.Message)
+}
+
+func (d Diagnostic) Error() string { return d.String() }
+
+// MarshalJSON writes the position as file, line, and column.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File     string   `json:"file"`
+		Line     int      `json:"line"`
+		Column   int      `json:"column"`
+		Severity Severity `json:"severity"`
+		Rule     string   `json:"rule"`
+		Message  string   `json:"message"`
+	}{relFile(d.Pos.Filename), d.Pos.Line, d.Pos.Column, d.Severity, d.Code, d.Message})
+}
+
+// Diagnostics is a list of problems; Parse and FindComments return this as an
+// error if there are problems.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	msg := ""
+	for _, diag := range d {
+		msg += diag.String() + "\n"
+	}
+	return fmt.Sprintf("%v\n%v errors occurred", msg, len(d))
+}
+
+// Sort the diagnostics by position.
+func (d Diagnostics) Sort() {
+	sort.SliceStable(d, func(i, j int) bool {
+		a, b := d[i].Pos, d[j].Pos
+		switch {
+		case a.Filename != b.Filename:
+			return a.Filename < b.Filename
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		default:
+			return a.Column < b.Column
+		}
+	})
+}
+
+// WriteText writes the diagnostics as "file:line:col: code: message", one per
+// line.
+func (d Diagnostics) WriteText(w io.Writer) error {
+	for _, diag := range d {
+		if _, err := fmt.Fprintln(w, diag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the diagnostics as a JSON array.
+func (d Diagnostics) WriteJSON(w io.Writer) error {
+	if d == nil {
+		d = Diagnostics{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteSARIF writes the diagnostics as a SARIF 2.1.0 log.
+//
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+func (d Diagnostics) WriteSARIF(w io.Writer) error {
+	type (
+		text struct {
+			Text string `json:"text"`
+		}
+		rule struct {
+			ID               string `json:"id"`
+			ShortDescription text   `json:"shortDescription"`
+		}
+		region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn,omitempty"`
+		}
+		artifactLocation struct {
+			URI string `json:"uri"`
+		}
+		physicalLocation struct {
+			ArtifactLocation artifactLocation `json:"artifactLocation"`
+			Region           *region          `json:"region,omitempty"`
+		}
+		location struct {
+			PhysicalLocation physicalLocation `json:"physicalLocation"`
+		}
+		result struct {
+			RuleID    string     `json:"ruleId"`
+			Level     Severity   `json:"level"`
+			Message   text       `json:"message"`
+			Locations []location `json:"locations"`
+		}
+		driver struct {
+			Name           string `json:"name"`
+			InformationURI string `json:"informationUri"`
+			Rules          []rule `json:"rules"`
+		}
+		run struct {
+			Tool struct {
+				Driver driver `json:"driver"`
+			} `json:"tool"`
+			Results []result `json:"results"`
+		}
+	)
+
+	descs := map[string]string{codeParseError: "error in a comment or Go file"}
+	for _, r := range LintRules {
+		descs[r.Code] = r.Desc
+	}
+
+	var (
+		rn    run
+		rules = make(map[string]struct{})
+	)
+	rn.Tool.Driver = driver{
+		Name:           "kommentaar",
+		InformationURI: "https://github.com/teamwork/kommentaar",
+		Rules:          []rule{},
+	}
+	rn.Results = make([]result, 0, len(d))
+	for _, diag := range d {
+		if _, ok := rules[diag.Code]; !ok {
+			rules[diag.Code] = struct{}{}
+			rn.Tool.Driver.Rules = append(rn.Tool.Driver.Rules, rule{diag.Code, text{descs[diag.Code]}})
+		}
+		loc := physicalLocation{
+			ArtifactLocation: artifactLocation{URI: strings.ReplaceAll(relFile(diag.Pos.Filename), `\`, "/")},
+		}
+		if diag.Pos.Line > 0 {
+			loc.Region = &region{StartLine: diag.Pos.Line, StartColumn: diag.Pos.Column}
+		}
+		rn.Results = append(rn.Results, result{
+			RuleID:    diag.Code,
+			Level:     diag.Severity,
+			Message:   text{diag.Message},
+			Locations: []location{{loc}},
+		})
+	}
+	sort.Slice(rn.Tool.Driver.Rules, func(i, j int) bool {
+		return rn.Tool.Driver.Rules[i].ID < rn.Tool.Driver.Rules[j].ID
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Version string `json:"version"`
+		Schema  string `json:"$schema"`
+		Runs    []run  `json:"runs"`
+	}{"2.1.0", "https://json.schemastore.org/sarif-2.1.0.json", []run{rn}})
+}
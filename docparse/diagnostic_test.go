@@ -0,0 +1,116 @@
+package docparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teamwork/test/diff"
+)
+
+func testDiagnostics(t *testing.T) Diagnostics {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Diagnostics{
+		{Pos: token.Position{Filename: filepath.Join(wd, "x", "a.go"), Line: 4, Column: 1},
+			Severity: SeverityError, Code: codeParseError, Message: "Path already present"},
+		{Pos: token.Position{Filename: filepath.Join(wd, "x", "b.go"), Line: 12, Column: 2},
+			Severity: SeverityWarning, Code: "path-doc", Message: "no documentation"},
+	}
+}
+
+func TestDiagnosticsError(t *testing.T) {
+	want := "x/a.go:4:1: parse-error: Path already present\n" +
+		"x/b.go:12:2: path-doc: no documentation\n" +
+		"\n2 errors occurred"
+	if d := diff.TextDiff(want, testDiagnostics(t).Error()); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestDiagnosticsJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := testDiagnostics(t).WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"file": "x/a.go", "line": 4.0, "column": 1.0, "severity": "error",
+			"rule": "parse-error", "message": "Path already present"},
+		{"file": "x/b.go", "line": 12.0, "column": 2.0, "severity": "warning",
+			"rule": "path-doc", "message": "no documentation"},
+	}
+	if !reflect.DeepEqual(want, out) {
+		t.Errorf("\n%v", diff.Diff(want, out))
+	}
+
+	buf.Reset()
+	if err := Diagnostics(nil).WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty: %q", buf.String())
+	}
+}
+
+func TestDiagnosticsSARIF(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := testDiagnostics(t).WriteSARIF(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine, StartColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Version != "2.1.0" || len(out.Runs) != 1 {
+		t.Fatalf("version %q with %d runs", out.Version, len(out.Runs))
+	}
+	r := out.Runs[0]
+	if len(r.Tool.Driver.Rules) != 2 || r.Tool.Driver.Rules[0].ID != "parse-error" ||
+		r.Tool.Driver.Rules[1].ID != "path-doc" {
+		t.Errorf("wrong rules: %v", r.Tool.Driver.Rules)
+	}
+	if len(r.Results) != 2 {
+		t.Fatalf("len(Results) = %d", len(r.Results))
+	}
+
+	res := r.Results[1]
+	loc := res.Locations[0].PhysicalLocation
+	if res.RuleID != "path-doc" || res.Level != "warning" || res.Message.Text != "no documentation" ||
+		loc.ArtifactLocation.URI != "x/b.go" || loc.Region.StartLine != 12 || loc.Region.StartColumn != 2 {
+		t.Errorf("wrong result: %+v", res)
+	}
+}
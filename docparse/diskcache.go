@@ -0,0 +1,312 @@
+package docparse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// DefaultCacheDir gets the default directory for Loader.SetCacheDir.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kommentaar"), nil
+}
+
+// diskCache stores package lists and the locations of declarations in files,
+// so they don't need to be loaded with "go list" or parsed in full on the next
+// run.
+//
+// All errors are ignored; the data is loaded normally if something is wrong
+// with the cache.
+type diskCache struct {
+	dir string
+	l   *Loader // For debug output; may be nil.
+}
+
+// cacheVersion is added to all keys; change it when the format of stored
+// entries changes.
+const cacheVersion = "1"
+
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range append([]string{cacheVersion, runtime.Version()}, parts...) {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *diskCache) path(kind, key string) string {
+	return filepath.Join(c.dir, kind, key[:2], key+".json")
+}
+
+func (c *diskCache) load(kind, key string, v interface{}) bool {
+	if c == nil || c.dir == "" {
+		return false
+	}
+	data, err := os.ReadFile(c.path(kind, key))
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		c.l.dbg("cache: %s/%s: %v", kind, key, err)
+		return false
+	}
+	return true
+}
+
+func (c *diskCache) store(kind, key string, v interface{}) {
+	if c == nil || c.dir == "" {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		c.l.dbg("cache: %s/%s: %v", kind, key, err)
+		return
+	}
+
+	// Write to a temporary file first, so concurrent runs never read a partial
+	// entry.
+	p := c.path(kind, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		c.l.dbg("cache: %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		c.l.dbg("cache: %v", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), p)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		c.l.dbg("cache: %v", err)
+	}
+}
+
+// cachedPackage is the information used from packages.Package.
+type cachedPackage struct {
+	Name    string
+	PkgPath string
+	GoFiles []string
+}
+
+// cachedPackages is a stored list of packages.
+type cachedPackages struct {
+	Roots       []string // Directories to walk for new packages.
+	Dirs        []string // All package directories.
+	Fingerprint string
+	Pkgs        []cachedPackage
+}
+
+// loadPackages loads the packages for patterns with load, or from the cache if
+// none of the package directories or module files changed.
+func (c *diskCache) loadPackages(patterns []string, load func() ([]*packages.Package, error)) ([]cachedPackage, error) {
+	if c == nil || c.dir == "" {
+		pkgs, err := load()
+		if err != nil {
+			return nil, err
+		}
+		out := make([]cachedPackage, 0, len(pkgs))
+		for _, p := range pkgs {
+			out = append(out, cachedPackage{Name: p.Name, PkgPath: p.PkgPath, GoFiles: p.GoFiles})
+		}
+		return out, nil
+	}
+
+	wd, _ := os.Getwd()
+	key := cacheKey(append([]string{wd, build.Default.GOOS, build.Default.GOARCH,
+		os.Getenv("GOFLAGS"), os.Getenv("GOWORK")}, patterns...)...)
+
+	var cached cachedPackages
+	if c.load("pkgs", key, &cached) && cached.Fingerprint == dirFingerprint(wd, cached.Roots, cached.Dirs) {
+		c.l.dbg("cache: using cached packages for %v", patterns)
+		return cached.Pkgs, nil
+	}
+
+	pkgs, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	cached = cachedPackages{Pkgs: make([]cachedPackage, 0, len(pkgs))}
+	seen := make(map[string]bool)
+	for _, p := range pkgs {
+		cached.Pkgs = append(cached.Pkgs, cachedPackage{Name: p.Name, PkgPath: p.PkgPath, GoFiles: p.GoFiles})
+		if len(p.GoFiles) > 0 {
+			if d := filepath.Dir(p.GoFiles[0]); !seen[d] {
+				seen[d] = true
+				cached.Dirs = append(cached.Dirs, d)
+			}
+		}
+	}
+	for _, p := range patterns {
+		// Only local patterns can be walked; new packages for import paths
+		// with "..." aren't picked up until the module files change.
+		if strings.HasSuffix(p, "/...") && (build.IsLocalImport(p) || filepath.IsAbs(p)) {
+			cached.Roots = append(cached.Roots, filepath.Join(wd, strings.TrimSuffix(p, "/...")))
+		}
+	}
+	sort.Strings(cached.Dirs)
+	cached.Fingerprint = dirFingerprint(wd, cached.Roots, cached.Dirs)
+	c.store("pkgs", key, cached)
+	return cached.Pkgs, nil
+}
+
+// dirFingerprint gets a hash of the names, sizes, and modification times of
+// all Go files in dirs, all directories below roots, and the module files for
+// wd.
+func dirFingerprint(wd string, roots, dirs []string) string {
+	h := sha256.New()
+	stat := func(path string) {
+		st, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00-\x00", path)
+			return
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, st.Size(), st.ModTime().UnixNano())
+	}
+
+	for d := wd; ; d = filepath.Dir(d) {
+		for _, f := range []string{"go.mod", "go.sum", "go.work", "vendor/modules.txt"} {
+			stat(filepath.Join(d, f))
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	for _, r := range roots {
+		_ = filepath.WalkDir(r, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			n := d.Name()
+			if path != r && (n == "testdata" || n == "vendor" || strings.HasPrefix(n, ".") || strings.HasPrefix(n, "_")) {
+				return filepath.SkipDir
+			}
+			fmt.Fprintf(h, "%s\x00", path)
+			return nil
+		})
+	}
+
+	for _, d := range dirs {
+		ents, err := os.ReadDir(d)
+		if err != nil {
+			fmt.Fprintf(h, "%s\x00-\x00", d)
+			continue
+		}
+		for _, e := range ents {
+			if strings.HasSuffix(e.Name(), ".go") {
+				stat(filepath.Join(d, e.Name()))
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseDecls parses the file at path in fset for getDecls.
+//
+// The byte ranges of the package clause and all declarations other than
+// functions are stored in the cache, keyed by the content hash. If the file
+// didn't change everything else is replaced with spaces before parsing, which
+// is a lot faster for files with large function bodies. Offsets and line
+// numbers stay the same.
+func (c *diskCache) parseDecls(fset *token.FileSet, path string) (*ast.File, error) {
+	if c == nil || c.dir == "" {
+		return parser.ParseFile(fset, path, nil, parser.ParseComments)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(src)
+	key := cacheKey(path, hex.EncodeToString(sum[:]))
+
+	var ranges [][2]int
+	if c.load("decls", key, &ranges) {
+		if f, err := parser.ParseFile(fset, path, keepRanges(src, ranges), parser.ParseComments); err == nil {
+			return f, nil
+		}
+	}
+
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return f, err
+	}
+	c.store("decls", key, declRanges(f, src))
+	return f, nil
+}
+
+// declRanges gets the byte ranges of the package clause and all GenDecls in f,
+// including their doc comments and the rest of the last line.
+func declRanges(f *ast.File, src []byte) [][2]int {
+	off := func(p token.Pos) int { return int(p - f.FileStart) }
+	eol := func(o int) int {
+		if i := bytes.IndexByte(src[o:], '\n'); i >= 0 {
+			return o + i
+		}
+		return len(src)
+	}
+
+	start := off(f.Package)
+	if f.Doc != nil {
+		start = off(f.Doc.Pos())
+	}
+	ranges := [][2]int{{start, eol(off(f.Name.End()))}}
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		start := off(gd.Pos())
+		if gd.Doc != nil {
+			start = off(gd.Doc.Pos())
+		}
+		ranges = append(ranges, [2]int{start, eol(off(gd.End()))})
+	}
+	return ranges
+}
+
+// keepRanges replaces everything in src outside of ranges with spaces, except
+// newlines.
+func keepRanges(src []byte, ranges [][2]int) []byte {
+	out := make([]byte, len(src))
+	for i, b := range src {
+		if b == '\n' {
+			out[i] = b
+		} else {
+			out[i] = ' '
+		}
+	}
+	for _, r := range ranges {
+		if r[0] < 0 || r[1] > len(src) || r[0] > r[1] {
+			return src
+		}
+		copy(out[r[0]:r[1]], src[r[0]:r[1]])
+	}
+	return out
+}
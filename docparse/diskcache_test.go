@@ -0,0 +1,137 @@
+package docparse
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/teamwork/utils/v2/sliceutil"
+
+	"github.com/teamwork/test/diff"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestParseDecls(t *testing.T) {
+	src := []byte(`// Package x.
+package x
+
+import "fmt"
+
+// T is a type.
+type T struct {
+	A string // Comment.
+}
+
+func f() {
+	fmt.Println("héllo")
+	type local struct{}
+}
+
+const (
+	C = 1 // One.
+	D = 2
+)
+
+/* Block. */
+var v = func() int { return 1 }()
+`)
+	file := filepath.Join(t.TempDir(), "x.go")
+	if err := os.WriteFile(file, src, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	decls := func(f *ast.File) []string {
+		var out []string
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.TypeSpec:
+				out = append(out, fmt.Sprintf("%s %d", n.Name.Name, n.Pos()-f.FileStart))
+			case *ast.ValueSpec:
+				out = append(out, fmt.Sprintf("%s %d", n.Names[0].Name, n.Pos()-f.FileStart))
+			case *ast.Comment:
+				out = append(out, n.Text)
+			}
+			return true
+		})
+		return out
+	}
+
+	full, err := parser.ParseFile(token.NewFileSet(), file, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decls(full)
+
+	c := &diskCache{dir: t.TempDir()}
+	for _, name := range []string{"miss", "hit"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := c.parseDecls(token.NewFileSet(), file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := decls(f)
+			if name == "hit" {
+				// Only the local type is removed.
+				want = sliceutil.Remove(want, "local 136")
+				if len(f.Decls) != 4 {
+					t.Errorf("len(f.Decls) = %d", len(f.Decls))
+				}
+			}
+			if d := diff.Diff(want, out); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+
+	kept := keepRanges(src, declRanges(full, src))
+	if len(kept) != len(src) || bytes.Count(kept, []byte("\n")) != bytes.Count(src, []byte("\n")) {
+		t.Errorf("length or lines changed:\n%s", kept)
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, "pkg")
+	if err := os.Mkdir(pkgDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkgDir, "a.go"), []byte("package pkg\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	loads := 0
+	load := func() ([]*packages.Package, error) {
+		loads++
+		return []*packages.Package{{Name: "pkg", PkgPath: "example.com/pkg",
+			GoFiles: []string{filepath.Join(pkgDir, "a.go")}}}, nil
+	}
+
+	c := &diskCache{dir: filepath.Join(dir, "cache")}
+	want := []cachedPackage{{Name: "pkg", PkgPath: "example.com/pkg",
+		GoFiles: []string{filepath.Join(pkgDir, "a.go")}}}
+	for i, wantLoads := range []int{1, 1, 2} {
+		if i == 2 {
+			// Adding a file changes the package.
+			if err := os.WriteFile(filepath.Join(pkgDir, "b.go"), []byte("package pkg\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		out, err := c.loadPackages([]string{"example.com/pkg"}, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, out) {
+			t.Errorf("%d\n%s", i, diff.Diff(want, out))
+		}
+		if loads != wantLoads {
+			t.Errorf("%d: loaded %d times; want %d", i, loads, wantLoads)
+		}
+	}
+}
@@ -2,13 +2,13 @@
 package docparse // import "github.com/teamwork/kommentaar/docparse"
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"html/template"
 	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -21,10 +21,25 @@ import (
 
 // Program is the entire program: all collected endpoints and all collected
 // references.
+//
+// A Program can't be used concurrently, but different Programs can be parsed
+// concurrently, also if they share a Loader.
 type Program struct {
+	Loader     *Loader // Loads packages; can be shared between Programs.
 	Config     Config
 	Endpoints  []*Endpoint
 	References map[string]Reference
+	Routes     []Route // Only collected if Config.Routes is set.
+}
+
+// Reset removes everything found by Parse, so the program can be parsed again;
+// the Loader and Config are kept.
+func (prog *Program) Reset() {
+	*prog = Program{
+		Loader:     prog.Loader,
+		Config:     prog.Config,
+		References: make(map[string]Reference),
+	}
 }
 
 // Config for the program.
@@ -33,6 +48,25 @@ type Config struct {
 	Packages []string
 	Output   func(io.Writer, *Program) error
 	Debug    bool
+	Routes   bool // Collect route registrations in Program.Routes.
+
+	// Only include endpoints, parameters, and properties visible to this
+	// audience; see FilterAudience.
+	Audience string
+
+	// Transforms are run in order after all endpoints are parsed and sorted,
+	// and before Output is called; they're not run if Routes is set.
+	//
+	// See DropTags, FilterAudience, RewritePrefix, DefaultTag,
+	// DefaultResponses, StripOmitDoc, and PruneReferences for some built-in
+	// transforms.
+	Transforms []func(*Program) error
+
+	// Directory for the on-disk cache of package lists and declarations of
+	// referenced packages. This isn't used by Parse, as the cache is shared by
+	// all Programs using the Loader; kconfig.Load enables it with
+	// Loader.SetCacheDir.
+	CacheDir string
 
 	// General information.
 	Title        string
@@ -52,6 +86,15 @@ type Config struct {
 	MapTypes          map[string]string
 	MapFormats        map[string]string
 
+	// Security schemes, and the default security requirements for endpoints
+	// which don't have a Security: directive.
+	SecurityScheme  map[string]SecurityScheme
+	DefaultSecurity []SecurityRequirement
+
+	// Lint rules to enable or disable, in addition to the defaults.
+	LintEnable  []string
+	LintDisable []string
+
 	// InferRequired marks response/body struct fields as required when the
 	// Go type implies presence: non-pointer fields without `omitempty` in
 	// the struct tag and without an explicit `{optional}` doc tag. Path,
@@ -67,15 +110,13 @@ type DefaultResponse struct {
 	Schema      Schema
 }
 
-// NewProgram creates a new Program instance.
+// NewProgram creates a new Program instance, with a new Loader.
 func NewProgram(dbg bool) *Program {
-	printDebug = dbg
-
-	// Clear cache; otherwise tests with -count 2 fail.
-	// TODO: figure out why; should work really.
-	declsCache = make(map[string][]declCache)
+	l := NewLoader()
+	l.Debug = dbg
 
 	return &Program{
+		Loader:     l,
 		References: make(map[string]Reference),
 		Config: Config{
 			DefaultRequestCt:  "application/json",
@@ -89,14 +130,6 @@ func NewProgram(dbg bool) *Program {
 	}
 }
 
-var printDebug bool
-
-func dbg(s string, a ...interface{}) {
-	if printDebug {
-		_, _ = fmt.Fprintf(os.Stderr, "\x1b[38;5;244mdbg docparse: "+s+"\x1b[0m\n", a...)
-	}
-}
-
 // Endpoint denotes a single API endpoint.
 type Endpoint struct {
 	Method    string   // HTTP method (e.g. POST, DELETE, etc.)
@@ -108,6 +141,17 @@ type Endpoint struct {
 	Responses map[int]Response
 	Extend    map[string]interface{} // Extension data to be applied on marshalling.
 	Pos, End  token.Position
+
+	// Accepted security schemes; nil means Config.DefaultSecurity is used and
+	// an empty slice means it doesn't require authentication.
+	Security []SecurityRequirement
+
+	Deprecated       bool   // Endpoint is deprecated.
+	DeprecatedReason string // Why it's deprecated, or what to use instead.
+
+	// Audiences which can see the endpoint; it's visible to everyone if this
+	// is empty.
+	Audience []string
 }
 
 // Request definition.
@@ -117,15 +161,29 @@ type Request struct {
 	Path        *Ref   // Path parameters (e.g. /foo/{id}).
 	Query       *Ref   // Query parameters  (e.g. ?foo=id).
 	Form        *Ref   // Form parameters.
+	Header      *Ref   // Header parameters.
+	Cookie      *Ref   // Cookie parameters.
+
+	// Example request body (optional).
+	Example interface{}
 }
 
 // Response definition.
 type Response struct {
 	ContentType string // Content-Type.
 	Body        *Ref   // Body.
+	Headers     *Ref   // Response headers (optional).
+
+	// Keyword used instead of a reference for the body: "{default}",
+	// "{empty}", or "{data}"; the Body.Description is set from this.
+	Keyword string
+
+	// Example response body (optional).
+	Example interface{}
 }
 
-// Ref parameters for the path, query, form, request body, or response body.
+// Ref parameters for the path, query, form, header, cookie, request body, or
+// response body.
 type Ref struct {
 	Description string
 	// Main reason to store as a string (and Refs as a map) for now is so that
@@ -134,7 +192,7 @@ type Ref struct {
 	Reference string // *Reference
 }
 
-// Param is a path, query, or form parameter.
+// Param is a path, query, form, header, or cookie parameter.
 type Param struct {
 	Name string // Parameter name
 	// Info     string   // Detailed description
@@ -154,7 +212,7 @@ type Reference struct {
 	File    string  // File this struct resides in.
 	Lookup  string  // Identifier as pkg.type.
 	Info    string  // Comment of the struct itself.
-	Context string  // Context we found it: path, query, form, req, resp.
+	Context string  // Context we found it: path, query, form, header, cookie, req, resp.
 	IsEmbed bool    // Is an embedded struct.
 	IsSlice bool    // Is a slice
 	Wrapper string  // Name of json obj to wrap Schema in
@@ -164,11 +222,13 @@ type Reference struct {
 }
 
 const (
-	ctxForm  = "form"
-	ctxPath  = "path"
-	ctxQuery = "query"
-	ctxReq   = "req"
-	ctxResp  = "resp"
+	ctxForm   = "form"
+	ctxPath   = "path"
+	ctxQuery  = "query"
+	ctxHeader = "header"
+	ctxCookie = "cookie"
+	ctxReq    = "req"
+	ctxResp   = "resp"
 
 	refDefault = "{default}"
 	refEmpty   = "{empty}"
@@ -178,13 +238,17 @@ const (
 var allRefs = []string{refDefault, refEmpty, refData}
 
 var (
-	reBasicHeader    = regexp.MustCompile(`^(Path|Form|Query|Extend): (.+)`)
+	reBasicHeader    = regexp.MustCompile(`^(Path|Form|Query|Header|Cookie|Security|Deprecated|Audience|Extend): (.+)`)
+	reDeprecated     = regexp.MustCompile(`^Deprecated:\s*$`)
 	reRequestHeader  = regexp.MustCompile(`^Request body( \((.+?)\))?: (.+)`)
 	reResponseHeader = regexp.MustCompile(`^Response( (\d+?))?( \((.+?)\))?: (.+)`)
+	reRespHeaders    = regexp.MustCompile(`^Response (\d+) headers: (.+)`)
+	reRequestExample = regexp.MustCompile(`^Request body example: (.+)`)
+	reRespExample    = regexp.MustCompile(`^Response (\d+) example: (.+)`)
 )
 
 // parseComment a single comment block in the file filePath.
-func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int, error) {
+func parseComment(ctx context.Context, prog *Program, comment, _, filePath string) ([]*Endpoint, int, error) {
 	e := &Endpoint{}
 
 	// Get start line and determine if this is a comment block.
@@ -240,8 +304,17 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 		// Form:
 		// Query:
 		// Path:
+		// Header:
+		// Cookie:
+		// Security:
+		// Deprecated:
+		// Audience:
 		// Extend:
 		h := reBasicHeader.FindStringSubmatch(line)
+		if h == nil && reDeprecated.MatchString(line) {
+			// The reason is optional.
+			h = []string{line, "Deprecated", ""}
+		}
 		if h != nil {
 			pastDesc = true
 			switch h[1] {
@@ -249,10 +322,10 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 				if e.Request.Path != nil {
 					return nil, i, fmt.Errorf("%v already present", h[1])
 				}
-				e.Request.Path, err = parseRefValue(prog, "path", h[2], filePath)
+				e.Request.Path, err = parseRefValue(ctx, prog, "path", h[2], filePath)
 
 				if err == nil {
-					pathRef, err := GetReference(prog, "query", false, e.Request.Path.Reference, filePath)
+					pathRef, err := getReference(ctx, prog, "query", false, e.Request.Path.Reference, filePath)
 					if err != nil {
 						return nil, i, err
 					}
@@ -278,18 +351,54 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 				if e.Request.Query != nil {
 					return nil, i, fmt.Errorf("%v already present", h[1])
 				}
-				e.Request.Query, err = parseRefValue(prog, "query", h[2], filePath)
+				e.Request.Query, err = parseRefValue(ctx, prog, "query", h[2], filePath)
 			case "Form":
 				if e.Request.Form != nil {
 					return nil, i, fmt.Errorf("%v already present", h[1])
 				}
-				e.Request.Form, err = parseRefValue(prog, "form", h[2], filePath)
+				e.Request.Form, err = parseRefValue(ctx, prog, "form", h[2], filePath)
+			case "Header":
+				if e.Request.Header != nil {
+					return nil, i, fmt.Errorf("%v already present", h[1])
+				}
+				e.Request.Header, err = parseRefValue(ctx, prog, "header", h[2], filePath)
+			case "Cookie":
+				if e.Request.Cookie != nil {
+					return nil, i, fmt.Errorf("%v already present", h[1])
+				}
+				e.Request.Cookie, err = parseRefValue(ctx, prog, "cookie", h[2], filePath)
+			case "Security":
+				none := strings.TrimSpace(h[2]) == refNone
+				if e.Security != nil && (none || len(e.Security) == 0) {
+					return nil, i, fmt.Errorf("%v: %v can't be combined with other schemes", h[1], refNone)
+				}
+				if none {
+					e.Security = []SecurityRequirement{}
+					break
+				}
+
+				req, err := ParseSecurity(prog, h[2])
+				if err != nil {
+					return nil, i, fmt.Errorf("%v: %v", h[1], err)
+				}
+				e.Security = append(e.Security, req)
+			case "Deprecated":
+				if e.Deprecated {
+					return nil, i, fmt.Errorf("%v already present", h[1])
+				}
+				e.Deprecated = true
+				e.DeprecatedReason = strings.TrimSpace(h[2])
+			case "Audience":
+				if e.Audience != nil {
+					return nil, i, fmt.Errorf("%v already present", h[1])
+				}
+				e.Audience = strings.Fields(h[2])
 			case "Extend":
 				if e.Extend != nil {
 					return nil, i, fmt.Errorf("%v already present", h[1])
 				}
 				extendPath := filepath.Join(filepath.Dir(filePath), h[2])
-				err := readAndUnmarshalSchemaFile(extendPath, &e.Extend)
+				err := prog.Loader.readSchemaFile(extendPath, &e.Extend)
 				if err != nil {
 					return nil, i, fmt.Errorf("%v: %v", h[1], err)
 				}
@@ -301,6 +410,24 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 			continue
 		}
 
+		// Request body example:
+		if ex := reRequestExample.FindStringSubmatch(line); ex != nil {
+			pastDesc = true
+			if e.Request.Body == nil || e.Request.Body.Reference == "" {
+				return nil, i, fmt.Errorf("%v: request body example defined before the request body",
+					e.Path)
+			}
+			if e.Request.Example != nil {
+				return nil, i, fmt.Errorf("%v: request body example defined more than once", e.Path)
+			}
+
+			e.Request.Example, err = parseExample(ctx, prog, ex[1], e.Request.Body.Reference, filePath)
+			if err != nil {
+				return nil, i, fmt.Errorf("request body example: %v", err)
+			}
+			continue
+		}
+
 		// Request body:
 		// Request body (application/json):
 		req := reRequestHeader.FindStringSubmatch(line)
@@ -315,7 +442,7 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 				e.Request.ContentType = req[2]
 			}
 
-			e.Request.Body, err = parseRefValue(prog, "req", req[3], filePath)
+			e.Request.Body, err = parseRefValue(ctx, prog, "req", req[3], filePath)
 			if err != nil {
 				return nil, i, fmt.Errorf("could not parse request params: %v", err)
 			}
@@ -323,10 +450,68 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 			continue
 		}
 
+		// Response 200 example:
+		if ex := reRespExample.FindStringSubmatch(line); ex != nil {
+			pastDesc = true
+			code, _ := strconv.Atoi(ex[1])
+			resp, ok := e.Responses[code]
+			if !ok {
+				return nil, i, fmt.Errorf("%v: example for response code %v defined before the response",
+					e.Path, code)
+			}
+			if resp.Example != nil {
+				return nil, i, fmt.Errorf("%v: example for response code %v defined more than once",
+					e.Path, code)
+			}
+
+			lookup := resp.Body.Reference
+			if lookup == "" {
+				// {empty} and {data} have no type.
+				dr, ok := prog.Config.DefaultResponse[code]
+				if !ok || resp.Keyword != refDefault || dr.Body == nil {
+					return nil, i, fmt.Errorf("%v: response %v has no body for the example", e.Path, code)
+				}
+				lookup = dr.Body.Reference
+			}
+
+			resp.Example, err = parseExample(ctx, prog, ex[2], lookup, filePath)
+			if err != nil {
+				return nil, i, fmt.Errorf("response %v example: %v", code, err)
+			}
+			e.Responses[code] = resp
+			continue
+		}
+
+		// Response 201 headers:
+		if rh := reRespHeaders.FindStringSubmatch(line); rh != nil {
+			pastDesc = true
+			code, _ := strconv.Atoi(rh[1])
+			resp, ok := e.Responses[code]
+			if !ok {
+				return nil, i, fmt.Errorf("%v: headers for response code %v defined before the response",
+					e.Path, code)
+			}
+			if resp.Headers != nil {
+				return nil, i, fmt.Errorf("%v: headers for response code %v defined more than once",
+					e.Path, code)
+			}
+			if strings.HasPrefix(strings.TrimSpace(rh[2]), "{") {
+				return nil, i, fmt.Errorf("%v: response headers must reference a struct: %q",
+					e.Path, rh[2])
+			}
+
+			resp.Headers, err = parseRefValue(ctx, prog, "header", rh[2], filePath)
+			if err != nil {
+				return nil, i, fmt.Errorf("could not parse response %v headers: %v", code, err)
+			}
+			e.Responses[code] = resp
+			continue
+		}
+
 		// Response 200 (application/json):
 		// Response 200:
 		// Response:
-		code, resp, err := ParseResponse(prog, filePath, line)
+		code, resp, err := parseResponse(ctx, prog, filePath, line)
 		if err != nil {
 			return nil, i, err
 		}
@@ -366,7 +551,7 @@ func parseComment(prog *Program, comment, _, filePath string) ([]*Endpoint, int,
 
 			lookup := m[1:] // strip "$"
 			name, pkg := ParseLookup(lookup, filePath)
-			vs, _, _, err := findValue(filePath, pkg, name)
+			vs, _, _, err := prog.Loader.findValue(ctx, filePath, pkg, name)
 			if err != nil {
 				expandErr = fmt.Errorf("%s: findValue: %v", m, err)
 				return ""
@@ -411,6 +596,10 @@ func PathParams(path string) []string {
 //
 // Exported so it can be used in the config, too.
 func ParseResponse(prog *Program, filePath, line string) (int, *Response, error) {
+	return parseResponse(context.Background(), prog, filePath, line)
+}
+
+func parseResponse(ctx context.Context, prog *Program, filePath, line string) (int, *Response, error) {
 	resp := reResponseHeader.FindStringSubmatch(line)
 	if resp == nil {
 		return 0, nil, nil
@@ -432,12 +621,15 @@ func ParseResponse(prog *Program, filePath, line string) (int, *Response, error)
 	}
 
 	var err error
-	r.Body, err = parseRefValue(prog, "resp", resp[5], filePath)
+	r.Body, err = parseRefValue(ctx, prog, "resp", resp[5], filePath)
 	if err != nil {
 		return 0, nil, fmt.Errorf("could not parse response %v params: %v", code, err)
 	}
 
 	codeText := fmt.Sprintf("%d %s", code, http.StatusText(int(code)))
+	if r.Body.Reference == "" {
+		r.Keyword = r.Body.Description
+	}
 	switch r.Body.Description {
 	case "":
 		r.Body.Description = codeText
@@ -491,7 +683,7 @@ func parseStartLine(line string) (string, string, []string) {
 }
 
 // Process a Kommentaar directive value.
-func parseRefValue(prog *Program, context, value, filePath string) (*Ref, error) {
+func parseRefValue(ctx context.Context, prog *Program, refContext, value, filePath string) (*Ref, error) {
 	params := &Ref{}
 	value = strings.TrimSpace(value)
 
@@ -505,7 +697,7 @@ func parseRefValue(prog *Program, context, value, filePath string) (*Ref, error)
 		return params, nil
 	}
 
-	ref, err := GetReference(prog, context, false, value, filePath)
+	ref, err := getReference(ctx, prog, refContext, false, value, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("GetReference: %v", err)
 	}
@@ -524,6 +716,9 @@ func hasTag(s, tag string) bool {
 }
 
 // parseTags get tags from {..} blocks.
+//
+// Tags are separated by commas; commas and braces in JSON values, such as
+// {example: {"a": 1, "b": 2}}, are part of the tag.
 func parseTags(line string) (string, []string) {
 	var alltags []string
 
@@ -533,13 +728,11 @@ func parseTags(line string) (string, []string) {
 			break
 		}
 
-		close := strings.Index(line, "}")
+		tags, close := splitTags(line[open+1:])
 		if close == -1 {
 			break
 		}
-
-		tags := strings.Split(line[open+1:close], ",")
-		line = line[:open] + line[close+1:]
+		line = line[:open] + line[open+1+close+1:]
 
 		for _, tag := range tags {
 			tag = strings.TrimSpace(tag)
@@ -574,6 +767,41 @@ func parseTags(line string) (string, []string) {
 	return nl, alltags
 }
 
+// splitTags splits the tags in s on commas, up to the } which closes the
+// block. Brackets and quoted strings are skipped, so JSON values can be used.
+//
+// The index of the closing } is returned, or -1 if the block isn't closed.
+func splitTags(s string) ([]string, int) {
+	var (
+		tags  []string
+		start int
+		depth int
+		str   bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case str && c == '\\':
+			i++
+		case str:
+			str = c != '"'
+		case c == '"':
+			str = true
+		case c == '{' || c == '[':
+			depth++
+		case c == ']' && depth > 0:
+			depth--
+		case c == '}' && depth > 0:
+			depth--
+		case c == '}':
+			return append(tags, s[start:i]), i
+		case c == ',' && depth == 0:
+			tags = append(tags, s[start:i])
+			start = i + 1
+		}
+	}
+	return nil, -1
+}
+
 // MapType maps some Go types to primitives, so they appear as such in the
 // output. Most of the time users of the API don't really care if it's a
 // "sql.NullString" or just a string.
@@ -1,7 +1,10 @@
 package docparse
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -13,6 +16,7 @@ func TestParseComments(t *testing.T) {
 	stdResp := map[int]Response{200: {
 		ContentType: "application/json",
 		Body:        &Ref{Description: "200 OK (no data)"},
+		Keyword:     "{empty}",
 	}}
 
 	tests := []struct {
@@ -282,15 +286,52 @@ Response 400 (w00t): {empty}
 					200: {
 						ContentType: "application/json",
 						Body:        &Ref{Description: "200 OK (no data)"},
+						Keyword:     "{empty}",
 					},
 					400: {
 						ContentType: "w00t",
 						Body:        &Ref{Description: "400 Bad Request (no data)"},
+						Keyword:     "{empty}",
 					},
 				},
 			}},
 		},
 
+		{"deprecated", `
+POST /path
+Old endpoint.
+
+Deprecated: use GET /path
+
+Response 200: {empty}
+			`,
+			"",
+			[]*Endpoint{{
+				Method:           "POST",
+				Path:             "/path",
+				Tagline:          "Old endpoint.",
+				Deprecated:       true,
+				DeprecatedReason: "use GET /path",
+			}},
+		},
+
+		{"deprecated-no-reason", `
+POST /path
+Old endpoint.
+
+Deprecated:
+
+Response 200: {empty}
+			`,
+			"",
+			[]*Endpoint{{
+				Method:     "POST",
+				Path:       "/path",
+				Tagline:    "Old endpoint.",
+				Deprecated: true,
+			}},
+		},
+
 		//	{"err-double-code", `
 		//		POST /path
 
@@ -308,7 +349,7 @@ Response 400 (w00t): {empty}
 			}
 			tt.in = test.NormalizeIndent(tt.in)
 
-			out, _, err := parseComment(prog, tt.in, ".", "docparse.go")
+			out, _, err := parseComment(context.Background(), prog, tt.in, ".", "docparse.go")
 			if !test.ErrorContains(err, tt.wantErr) {
 				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
 			}
@@ -319,6 +360,40 @@ Response 400 (w00t): {empty}
 	}
 }
 
+func TestParseCommentsResponseExample(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.StructTag = "json"
+	prog.Config.DefaultResponse = make(map[int]Response)
+	for _, line := range []string{"Response 204: net/mail.Address", "Response 400: net/mail.Address"} {
+		code, resp, err := ParseResponse(prog, "", line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prog.Config.DefaultResponse[code] = *resp
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ex.json"), []byte(`{"Name": "x"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in, wantErr string
+	}{
+		{"Response 400: {default}\nResponse 400 example: ex.json", ""},
+		{"Response 204: {empty}\nResponse 204 example: ex.json", "has no body for the example"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, _, err := parseComment(context.Background(), prog, "POST /path\n\n"+tt.in, ".", filepath.Join(dir, "x.go"))
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestGetStartLine(t *testing.T) {
 	tests := []struct {
 		in, wantMethod, wantPath string
@@ -448,6 +523,11 @@ func TestParseParamsTags(t *testing.T) {
 		{"hello {  } { } world", "hello world", nil},
 		{"Hello there {int}.", "Hello there.", []string{"int"}},
 		{"Hello {enum: one two three}", "Hello", []string{"enum: one two three"}},
+		{`Hello {example: {"a": 1, "b": [2, 3]}, required}`, "Hello",
+			[]string{`example: {"a": 1, "b": [2, 3]}`, "required"}},
+		{`Hello {example: "x, }"}`, "Hello", []string{`example: "x, }"`}},
+		{`Hello {example: "\"}"}`, "Hello", []string{`example: "\"}"`}},
+		{"Hello {int", "Hello {int", nil},
 	}
 
 	for _, tt := range tests {
@@ -614,6 +694,16 @@ func TestParseResponse(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"Response 204: {empty}",
+			204,
+			&Response{
+				ContentType: "application/json",
+				Body:        &Ref{Description: "204 No Content (no data)"},
+				Keyword:     "{empty}",
+			},
+			"",
+		},
 		{
 			"Response 400 net/mail.Address",
 			0,
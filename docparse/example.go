@@ -0,0 +1,391 @@
+package docparse
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/teamwork/utils/v2/goutil"
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+// parseExample loads the example in value for the reference lookup, and
+// validates that it matches the schema.
+//
+// The value is either a path to a JSON or YAML file relative to filePath, or a
+// Go variable as $name, $pkg.name, or $import/path.name.
+func parseExample(ctx context.Context, prog *Program, value, lookup, filePath string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+
+	var (
+		v   interface{}
+		err error
+	)
+	if strings.HasPrefix(value, "$") {
+		v, err = exampleVar(ctx, prog, value[1:], filePath)
+	} else {
+		err = prog.Loader.readSchemaFile(filepath.Join(filepath.Dir(filePath), value), &v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ref, ok := prog.References[lookup]
+	if !ok || ref.Schema == nil {
+		return nil, fmt.Errorf("no schema for %q", lookup)
+	}
+	if err := validateExample(prog, v, ref.Schema, ""); err != nil {
+		return nil, fmt.Errorf("does not match %s: %v", lookup, err)
+	}
+	return v, nil
+}
+
+// exampleVar gets the value of the Go variable (or constant) lookup.
+func exampleVar(ctx context.Context, prog *Program, lookup, filePath string) (interface{}, error) {
+	name, pkg := ParseLookup(lookup, filePath)
+	vs, file, _, err := prog.Loader.findValue(ctx, filePath, pkg, name)
+	if err != nil {
+		return nil, fmt.Errorf("findValue: %v", err)
+	}
+
+	for i, n := range vs.Names {
+		if n.Name == name && i < len(vs.Values) {
+			return exampleValue(ctx, prog, vs.Values[i], file, vs.Type, file)
+		}
+	}
+	return nil, fmt.Errorf("%s has no value", lookup)
+}
+
+// exampleValue converts the Go expression e to a value that can be marshalled
+// as JSON; struct fields are named after the configured struct tag.
+//
+// e is located in file; typ is the type of e if it's not in the expression
+// itself (e.g. for composite literals in a slice), and typFile is the file
+// where typ is located.
+func exampleValue(ctx context.Context, prog *Program, e ast.Expr, file string, typ ast.Expr, typFile string) (interface{}, error) {
+	switch n := e.(type) {
+	case *ast.ParenExpr:
+		return exampleValue(ctx, prog, n.X, file, typ, typFile)
+
+	case *ast.BasicLit:
+		switch n.Kind {
+		case token.INT:
+			return strconv.ParseInt(n.Value, 0, 64)
+		case token.FLOAT:
+			return strconv.ParseFloat(n.Value, 64)
+		case token.STRING, token.CHAR:
+			return strconv.Unquote(n.Value)
+		}
+
+	case *ast.UnaryExpr:
+		v, err := exampleValue(ctx, prog, n.X, file, typ, typFile)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case token.AND, token.ADD:
+			return v, nil
+		case token.SUB:
+			switch v := v.(type) {
+			case int64:
+				return -v, nil
+			case float64:
+				return -v, nil
+			}
+		}
+
+	case *ast.Ident:
+		switch n.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "nil":
+			return nil, nil
+		}
+		return exampleVar(ctx, prog, n.Name, file)
+
+	case *ast.SelectorExpr:
+		if x, ok := n.X.(*ast.Ident); ok {
+			return exampleVar(ctx, prog, x.Name+"."+n.Sel.Name, file)
+		}
+
+	case *ast.CompositeLit:
+		if n.Type != nil {
+			typ, typFile = n.Type, file
+		}
+		typ, typFile, err := prog.Loader.underlyingType(ctx, typ, typFile)
+		if err != nil {
+			return nil, err
+		}
+		return exampleComposite(ctx, prog, n, file, typ, typFile)
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T in example", e)
+}
+
+func exampleComposite(ctx context.Context, prog *Program, n *ast.CompositeLit, file string, typ ast.Expr, typFile string) (interface{}, error) {
+	switch t := typ.(type) {
+	case *ast.ArrayType:
+		l := make([]interface{}, 0, len(n.Elts))
+		for _, elt := range n.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			v, err := exampleValue(ctx, prog, elt, file, t.Elt, typFile)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+
+	case *ast.MapType:
+		m := make(map[string]interface{}, len(n.Elts))
+		for _, elt := range n.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil, fmt.Errorf("map element without key in example")
+			}
+			k, err := exampleValue(ctx, prog, kv.Key, file, t.Key, typFile)
+			if err != nil {
+				return nil, err
+			}
+			v, err := exampleValue(ctx, prog, kv.Value, file, t.Value, typFile)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = v
+		}
+		return m, nil
+
+	case *ast.StructType:
+		m := make(map[string]interface{}, len(n.Elts))
+		for i, elt := range n.Elts {
+			var (
+				fName = ""
+				val   = elt
+			)
+			kv, ok := elt.(*ast.KeyValueExpr)
+			switch {
+			case ok:
+				k, isIdent := kv.Key.(*ast.Ident)
+				if !isIdent {
+					return nil, fmt.Errorf("invalid struct key in example: %T", kv.Key)
+				}
+				fName, val = k.Name, kv.Value
+			case i < len(t.Fields.List):
+				// Unkeyed literal.
+				if len(t.Fields.List[i].Names) > 0 {
+					fName = t.Fields.List[i].Names[0].Name
+				} else {
+					fName = exprName(t.Fields.List[i].Type)
+				}
+			}
+
+			var f *ast.Field
+			for _, sf := range t.Fields.List {
+				if len(sf.Names) == 0 && exprName(sf.Type) == fName {
+					f = sf
+					break
+				}
+				for _, sn := range sf.Names {
+					if sn.Name == fName {
+						f = sf
+					}
+				}
+			}
+			if f == nil {
+				return nil, fmt.Errorf("unknown field %q in example", fName)
+			}
+
+			v, err := exampleValue(ctx, prog, val, file, f.Type, typFile)
+			if err != nil {
+				return nil, err
+			}
+
+			name := fName
+			if len(f.Names) <= 1 {
+				name = goutil.TagName(f, prog.Config.StructTag)
+			}
+			if name == "-" {
+				continue
+			}
+
+			// Merge embedded structs without a tag.
+			if len(f.Names) == 0 && name == exprName(f.Type) {
+				if em, ok := v.(map[string]interface{}); ok {
+					for k, ev := range em {
+						if _, ok := m[k]; !ok {
+							m[k] = ev
+						}
+					}
+					continue
+				}
+			}
+			m[name] = v
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %T for composite literal in example", typ)
+}
+
+// underlyingType resolves the named type typ to its declaration.
+func (l *Loader) underlyingType(ctx context.Context, typ ast.Expr, file string) (ast.Expr, string, error) {
+	for {
+		var lookup string
+		switch t := typ.(type) {
+		case nil:
+			return nil, "", fmt.Errorf("unknown type for composite literal in example")
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.ParenExpr:
+			typ = t.X
+			continue
+		case *ast.Ident:
+			lookup = t.Name
+		case *ast.SelectorExpr:
+			x, ok := t.X.(*ast.Ident)
+			if !ok {
+				return typ, file, nil
+			}
+			lookup = x.Name + "." + t.Sel.Name
+		default:
+			return typ, file, nil
+		}
+
+		name, pkg := ParseLookup(lookup, file)
+		ts, f, _, err := l.findType(ctx, file, pkg, name)
+		if err != nil {
+			return nil, "", err
+		}
+		typ, file = ts.Type, f
+	}
+}
+
+// exprName gets the type name of embedded fields.
+func exprName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return exprName(t.X)
+	case *ast.Ident:
+		return t.Name
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
+
+// validateExample checks if the example v is valid for the schema s.
+func validateExample(prog *Program, v interface{}, s *Schema, path string) error {
+	if s == nil || s.CustomSchema != "" {
+		return nil
+	}
+	if s.Reference != "" {
+		ref, ok := prog.References[strings.TrimPrefix(s.Reference, "#/definitions/")]
+		if !ok {
+			return nil
+		}
+		return validateExample(prog, v, ref.Schema, path)
+	}
+
+	if path == "" {
+		path = "."
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null but should be %s", path, s.Type)
+	}
+
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %T but should be an object", path, v)
+		}
+
+		for _, r := range s.Required {
+			if _, ok := m[r]; !ok {
+				return fmt.Errorf("%s: required field %q is missing", path, r)
+			}
+		}
+
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p, ok := s.Properties[k]
+			if !ok {
+				p = s.AdditionalProperties
+				if p == nil && len(s.Properties) > 0 {
+					return fmt.Errorf("%s: unknown field %q", path, k)
+				}
+			}
+			if err := validateExample(prog, m[k], p, strings.TrimSuffix(path, ".")+"."+k); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return fmt.Errorf("%s: %T but should be an array", path, v)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			err := validateExample(prog, rv.Index(i).Interface(), s.Items, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: %T but should be a string", path, v)
+		}
+		if len(s.Enum) > 0 && !sliceutil.Contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %s", path, str, strings.Join(s.Enum, ", "))
+		}
+
+	case "integer", "number":
+		var f float64
+		switch n := v.(type) {
+		case int:
+			f = float64(n)
+		case int64:
+			f = float64(n)
+		case uint64:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return fmt.Errorf("%s: %T but should be a number", path, v)
+		}
+		if s.Type == "integer" && f != math.Trunc(f) {
+			return fmt.Errorf("%s: %v is not an integer", path, v)
+		}
+		if len(s.Enum) > 0 && !sliceutil.Contains(s.Enum, strconv.FormatFloat(f, 'f', -1, 64)) {
+			return fmt.Errorf("%s: %v is not one of %s", path, v, strings.Join(s.Enum, ", "))
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: %T but should be a boolean", path, v)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package docparse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/teamwork/test"
+)
+
+func TestValidateExample(t *testing.T) {
+	prog := NewProgram(false)
+	prog.References["pkg.item"] = Reference{Schema: &Schema{
+		Type:     "object",
+		Required: []string{"id"},
+		Properties: map[string]*Schema{
+			"id":   {Type: "integer"},
+			"tags": {Type: "array", Items: &Schema{Type: "string", Enum: []string{"a", "b"}}},
+			"ptr":  {Type: "boolean", Nullable: true},
+		},
+	}}
+	s := &Schema{Reference: "pkg.item"}
+
+	tests := []struct {
+		in      interface{}
+		wantErr string
+	}{
+		{map[string]interface{}{"id": 1}, ""},
+		{map[string]interface{}{"id": float64(1), "tags": []interface{}{"a"}, "ptr": nil}, ""},
+		{map[string]interface{}{"id": int64(1), "ptr": true}, ""},
+
+		{"x", ".: string but should be an object"},
+		{map[string]interface{}{}, `required field "id" is missing`},
+		{map[string]interface{}{"id": 1.5}, ".id: 1.5 is not an integer"},
+		{map[string]interface{}{"id": "1"}, ".id: string but should be a number"},
+		{map[string]interface{}{"id": 1, "x": 1}, `unknown field "x"`},
+		{map[string]interface{}{"id": 1, "tags": []interface{}{"c"}}, `.tags[0]: "c" is not one of a, b`},
+		{map[string]interface{}{"id": 1, "tags": "a"}, ".tags: string but should be an array"},
+		{map[string]interface{}{"id": nil}, ".id: null but should be integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run("", func(t *testing.T) {
+			err := validateExample(prog, tt.in, s, "")
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTypedExample(t *testing.T) {
+	tests := []struct {
+		schema  *Schema
+		in      string
+		want    interface{}
+		wantErr string
+	}{
+		{&Schema{Type: "string"}, "x", "x", ""},
+		{&Schema{Type: "integer"}, "42", int64(42), ""},
+		{&Schema{Type: "number"}, "4.2", 4.2, ""},
+		{&Schema{Type: "boolean"}, "true", true, ""},
+		{&Schema{Type: "array", Items: &Schema{Type: "integer"}}, "1 2", []interface{}{int64(1), int64(2)}, ""},
+		{&Schema{Type: "array", Items: &Schema{Type: "string"}}, `["a b"]`, []interface{}{"a b"}, ""},
+		{&Schema{Type: "object", Properties: map[string]*Schema{"a": {Type: "string"}}},
+			`{"a": "x"}`, map[string]interface{}{"a": "x"}, ""},
+		{&Schema{Type: "string", Enum: []string{"a", "b"}}, "b", "b", ""},
+
+		{&Schema{Type: "integer"}, "x", nil, "invalid syntax"},
+		{&Schema{Type: "boolean"}, "yes", nil, "invalid syntax"},
+		{&Schema{Type: "string", Enum: []string{"a", "b"}}, "c", nil, "not one of"},
+		{&Schema{Type: "object", Properties: map[string]*Schema{"a": {Type: "string"}}},
+			`{"a": 1}`, nil, ".a: float64 but should be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			out, err := typedExample(NewProgram(false), tt.schema, tt.in)
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(tt.want, out) {
+				t.Errorf("\nout:  %#v\nwant: %#v", out, tt.want)
+			}
+		})
+	}
+}
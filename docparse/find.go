@@ -1,10 +1,13 @@
 package docparse
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/build"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"io"
 	"os"
@@ -32,9 +35,48 @@ type parsedFile struct {
 	err      error
 }
 
-// FindComments finds all comments in the given paths or packages.
+// FindComments finds all comments in the given paths or packages, runs
+// Config.Transforms, and writes the output with Config.Output.
 func FindComments(w io.Writer, prog *Program) error {
-	pkgs, err := goutil.Expand(prog.Config.Packages, packages.NeedName|packages.NeedFiles)
+	return FindCommentsContext(context.Background(), w, prog)
+}
+
+// FindCommentsContext is like FindComments, but stops loading and parsing
+// packages when ctx is cancelled, and returns ctx.Err().
+func FindCommentsContext(ctx context.Context, w io.Writer, prog *Program) error {
+	if err := ParseContext(ctx, prog); err != nil {
+		return err
+	}
+	if !prog.Config.Routes {
+		// Routes are compared with the paths as they're documented.
+		if err := Transform(prog); err != nil {
+			return err
+		}
+	}
+
+	// It's probably better to call this per package or file, rather than once
+	// for everything (much more memory-efficient for large packages). OTOH,
+	// perhaps this is "good enough"?
+	// Note: making this more efficient means http.ServeHTML is also harder.
+	return prog.Config.Output(w, prog)
+}
+
+// Parse finds all endpoints in the given paths or packages, and stores them in
+// prog.Endpoints. Config.Prefix is added to the paths, unless Config.Routes is
+// set.
+//
+// Errors in files or comments don't stop the parsing; the endpoints which can
+// be parsed are stored, and the errors are returned as Diagnostics.
+func Parse(prog *Program) error {
+	return ParseContext(context.Background(), prog)
+}
+
+// ParseContext is like Parse, but stops loading and parsing packages when ctx
+// is cancelled, and returns ctx.Err().
+func ParseContext(ctx context.Context, prog *Program) error {
+	pkgs, err := prog.Loader.disk.Load().loadPackages(prog.Config.Packages, func() ([]*packages.Package, error) {
+		return expand(ctx, prog.Config.Packages, packages.NeedName|packages.NeedFiles)
+	})
 	if err != nil {
 		return err
 	}
@@ -64,6 +106,10 @@ func FindComments(w io.Writer, prog *Program) error {
 	for i, job := range jobs {
 		go func() {
 			defer wg.Done()
+			if err := ctx.Err(); err != nil {
+				parsed[i] = parsedFile{err: err}
+				return
+			}
 			relPath := job.pkgPath + "/" + filepath.Base(job.fullPath)
 			fset := token.NewFileSet()
 			f, parseErr := parser.ParseFile(fset, job.fullPath, nil, parser.ParseComments)
@@ -78,29 +124,57 @@ func FindComments(w io.Writer, prog *Program) error {
 		}()
 	}
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	// Only cache files without errors; ParseFile can return a partial AST with an error.
+	// Only cache files without errors; ParseFile can return a partial AST with an
+	// error. Files with errors are still watched, as fixing them changes the
+	// output.
 	for i := range parsed {
+		if parsed[i].err != nil && parsed[i].fullPath != "" {
+			prog.Loader.broken.Store(parsed[i].fullPath, struct{}{})
+		}
 		if parsed[i].astFile != nil && parsed[i].err == nil {
-			astFileCache.Store(parsed[i].fullPath, parsed[i].astFile)
+			prog.Loader.files.Store(parsed[i].fullPath, cachedFile{
+				ast: parsed[i].astFile,
+				tok: parsed[i].fset.File(parsed[i].astFile.FileStart),
+			})
 		}
 	}
 
-	preloadPackages(parsed)
+	prog.Loader.preloadPackages(ctx, parsed)
 
-	allErr := []error{}
+	allErr := Diagnostics{}
 	for _, pf := range parsed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if pf.err != nil {
-			allErr = append(allErr, pf.err)
+			var serr scanner.ErrorList
+			if !errors.As(pf.err, &serr) {
+				allErr = append(allErr, Diagnostic{Pos: token.Position{Filename: pf.fullPath},
+					Severity: SeverityError, Code: codeParseError, Message: pf.err.Error()})
+				continue
+			}
+			for _, e := range serr {
+				allErr = append(allErr, Diagnostic{Pos: e.Pos, Severity: SeverityError,
+					Code: codeParseError, Message: e.Msg})
+			}
 			continue
 		}
 
+		if prog.Config.Routes {
+			prog.Routes = append(prog.Routes, prog.Loader.collectRoutes(pf.fset, pf.astFile)...)
+		}
+
 		for _, c := range pf.astFile.Comments {
-			e, relLine, err := parseComment(prog, c.Text(), pf.pkgPath, pf.fullPath)
+			e, relLine, err := parseComment(ctx, prog, c.Text(), pf.pkgPath, pf.fullPath)
 			if err != nil {
 				p := pf.fset.Position(c.Pos())
-				allErr = append(allErr, fmt.Errorf("%v:%v %v",
-					pf.relPath, p.Line+relLine, err))
+				p.Line += relLine
+				allErr = append(allErr, Diagnostic{Pos: p, Severity: SeverityError,
+					Code: codeParseError, Message: err.Error()})
 				continue
 			}
 			if e == nil || e[0] == nil {
@@ -121,13 +195,10 @@ func FindComments(w io.Writer, prog *Program) error {
 			prog.Endpoints = append(prog.Endpoints, e...)
 		}
 	}
-
-	if len(allErr) > 0 {
-		msg := ""
-		for _, err := range allErr {
-			msg += err.Error() + "\n"
-		}
-		return fmt.Errorf("%v\n%v errors occurred", msg, len(allErr))
+	// Resolving a reference fails if ctx is cancelled, which is reported as a
+	// parse error of that comment.
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	// Sort endpoints by tags first, then method, and then path.
@@ -138,16 +209,23 @@ func FindComments(w io.Writer, prog *Program) error {
 		return key(prog.Endpoints[i]) < key(prog.Endpoints[j])
 	})
 
-	// It's probably better to call this per package or file, rather than once
-	// for everything (much more memory-efficient for large packages). OTOH,
-	// perhaps this is "good enough"?
-	// Note: making this more efficient means http.ServeHTML is also harder.
-	return prog.Config.Output(w, prog)
+	// Routes are compared with the paths as they're documented.
+	if prog.Config.Prefix != "" && !prog.Config.Routes {
+		for _, e := range prog.Endpoints {
+			e.Path = prog.Config.Prefix + e.Path
+		}
+	}
+
+	if len(allErr) > 0 {
+		allErr.Sort()
+		return allErr
+	}
+	return nil
 }
 
 // preloadPackages resolves all imported packages in one packages.Load call and
-// pre-populates resolvedPkgCache and declsCache before endpoint processing.
-func preloadPackages(parsed []parsedFile) {
+// pre-populates the package and declaration caches before endpoint processing.
+func (l *Loader) preloadPackages(ctx context.Context, parsed []parsedFile) {
 	seen := make(map[string]bool)
 	var importPaths []string
 	for _, pf := range parsed {
@@ -165,11 +243,14 @@ func preloadPackages(parsed []parsedFile) {
 	if len(importPaths) == 0 {
 		return
 	}
+	sort.Strings(importPaths)
 
-	pkgs, err := packages.Load(
-		&packages.Config{Mode: packages.NeedName | packages.NeedFiles},
-		importPaths...,
-	)
+	pkgs, err := l.disk.Load().loadPackages(importPaths, func() ([]*packages.Package, error) {
+		return packages.Load(
+			&packages.Config{Context: ctx, Mode: packages.NeedName | packages.NeedFiles},
+			importPaths...,
+		)
+	})
 	if err != nil {
 		return
 	}
@@ -189,21 +270,43 @@ func preloadPackages(parsed []parsedFile) {
 			Name:       pkg.Name,
 			ImportPath: pkg.PkgPath,
 		}
-		resolvedPkgCache.Store(pkg.PkgPath, pkgResult{pkg: bp})
-		getDecls(bp, pkg.PkgPath) //nolint:errcheck
+		l.pkgs.Store(pkg.PkgPath, pkgResult{pkg: bp})
+		l.getDecls(bp, pkg.PkgPath) //nolint:errcheck
 	}
 }
 
+// expand is like goutil.Expand, but passes ctx to packages.Load.
+func expand(ctx context.Context, paths []string, mode packages.LoadMode) ([]*packages.Package, error) {
+	pkgs, err := packages.Load(&packages.Config{Context: ctx, Mode: mode}, paths...)
+	if ctx.Err() != nil {
+		// The error from packages.Load doesn't wrap this.
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*packages.Package, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, pkg.Errors[0]
+		}
+		out = append(out, pkg)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("cannot find package")
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].PkgPath < out[j].PkgPath })
+	return out, nil
+}
+
 type declCache struct {
 	ts   *ast.TypeSpec
 	vs   *ast.ValueSpec
 	file string
 }
 
-var declsCache = make(map[string][]declCache)
-
-var astFileCache sync.Map // key: string, value: *ast.File
-
 // findType attempts to find a type.
 //
 // currentFile is the current file being parsed.
@@ -212,19 +315,19 @@ var astFileCache sync.Map // key: string, value: *ast.File
 // fully qualified path (i.e. "github.com/user/pkg") or a package from the
 // currentPkg imports (i.e. "models" will resolve to "github.com/desk/models" if
 // that is imported in currentPkg).
-func findType(currentFile, pkgPath, name string) (
+func (l *Loader) findType(ctx context.Context, currentFile, pkgPath, name string) (
 	ts *ast.TypeSpec,
 	filePath string,
 	importPath string,
 	err error,
 ) {
-	dbg("findType: file: %#v, pkgPath: %#v, name: %#v", currentFile, pkgPath, name)
-	resolvedPath, pkg, err := resolvePackage(currentFile, pkgPath)
+	l.dbg("findType: file: %#v, pkgPath: %#v, name: %#v", currentFile, pkgPath, name)
+	resolvedPath, pkg, err := l.resolvePackage(ctx, currentFile, pkgPath)
 	if err != nil {
 		return nil, "", "", fmt.Errorf("could not resolve package: %v", err)
 	}
 
-	decls, err := getDecls(pkg, resolvedPath)
+	decls, err := l.getDecls(pkg, resolvedPath)
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -282,19 +385,19 @@ func moduleImportPath(dir string) (string, error) {
 	return "", fmt.Errorf("go.mod not found")
 }
 
-func findValue(currentFile, pkgPath, name string) (
+func (l *Loader) findValue(ctx context.Context, currentFile, pkgPath, name string) (
 	vs *ast.ValueSpec,
 	filePath string,
 	importPath string,
 	err error,
 ) {
-	dbg("findValue: file: %#v, pkgPath: %#v, name: %#v", currentFile, pkgPath, name)
-	resolvedPath, pkg, err := resolvePackage(currentFile, pkgPath)
+	l.dbg("findValue: file: %#v, pkgPath: %#v, name: %#v", currentFile, pkgPath, name)
+	resolvedPath, pkg, err := l.resolvePackage(ctx, currentFile, pkgPath)
 	if err != nil {
 		return nil, "", "", fmt.Errorf("could not resolve package: %v", err)
 	}
 
-	decls, err := getDecls(pkg, resolvedPath)
+	decls, err := l.getDecls(pkg, resolvedPath)
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -323,42 +426,128 @@ type pkgResult struct {
 	err error
 }
 
-var resolvedPkgCache sync.Map // key: string, value: pkgResult
-
 // resolvePackageWithFallback resolves a package with vendor-aware lookup,
 // falling back to build.IgnoreVendor, and caches both success and failure.
-func resolvePackageWithFallback(pkgPath string) (*build.Package, error) {
-	if v, ok := resolvedPkgCache.Load(pkgPath); ok {
+func (l *Loader) resolvePackageWithFallback(ctx context.Context, pkgPath string) (*build.Package, error) {
+	if v, ok := l.pkgs.Load(pkgPath); ok {
 		r := v.(pkgResult)
 		return r.pkg, r.err
 	}
-	pkg, err := goutil.ResolvePackage(pkgPath, 0)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pkg, err := l.importPackage(ctx, pkgPath, 0)
+	if ctx.Err() != nil {
+		// Don't cache this; it may resolve fine with another context.
+		return nil, ctx.Err()
+	}
 	if err != nil {
-		if pkg2, err2 := goutil.ResolvePackage(pkgPath, build.IgnoreVendor); err2 == nil {
-			resolvedPkgCache.Store(pkgPath, pkgResult{pkg: pkg2})
+		if pkg2, err2 := l.importPackage(ctx, pkgPath, build.IgnoreVendor); err2 == nil {
+			l.pkgs.Store(pkgPath, pkgResult{pkg: pkg2})
 			return pkg2, nil
 		} else {
-			resolvedPkgCache.Store(pkgPath, pkgResult{err: err2})
+			l.pkgs.Store(pkgPath, pkgResult{err: err2})
 			return nil, err2
 		}
 	}
-	resolvedPkgCache.Store(pkgPath, pkgResult{pkg: pkg})
+	l.pkgs.Store(pkgPath, pkgResult{pkg: pkg})
 	return pkg, nil
 }
 
-func resolvePackage(currentFile, pkgPath string) (
+// importPackage is like goutil.ResolvePackage, but resolves relative paths and
+// import paths from the working directory of the Loader.
+//
+// build.Import runs "go list" in module mode, which can't be cancelled; this
+// stops waiting for it and returns ctx.Err() when ctx is cancelled. The
+// goroutine running build.Import is left behind until "go list" exits, so
+// every cancelled call may leave one running for a short while; no new import
+// is started if ctx is already cancelled.
+func (l *Loader) importPackage(ctx context.Context, pkgPath string, mode build.ImportMode) (*build.Package, error) {
+	l.cwdOnce.Do(func() { l.cwd, l.cwdErr = os.Getwd() })
+	if l.cwdErr != nil {
+		return nil, l.cwdErr
+	}
+
+	switch {
+	case pkgPath == "":
+		return nil, errors.New("cannot resolve empty string")
+	case filepath.IsAbs(pkgPath):
+		return build.ImportDir(pkgPath, mode)
+	case pkgPath[0] == '.':
+		return build.ImportDir(filepath.Join(l.cwd, pkgPath), mode)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		pkg *build.Package
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		pkg, err := build.Import(pkgPath, l.cwd, mode)
+		ch <- result{pkg, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.pkg, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolveImport is like goutil.ResolveImport, but caches the imports in the
+// Loader, and uses the already parsed AST if there is one.
+func (l *Loader) resolveImport(file, pkgName string) (string, error) {
+	v, ok := l.imports.Load(file)
+	if !ok {
+		var specs []*ast.ImportSpec
+		if f, ok := l.files.Load(file); ok {
+			specs = f.(cachedFile).ast.Imports
+		} else {
+			f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+			if err != nil {
+				return "", err
+			}
+			specs = f.Imports
+		}
+
+		imports := make(map[string]string, len(specs))
+		for _, imp := range specs {
+			p := strings.Trim(imp.Path.Value, `"`)
+			if imp.Name != nil {
+				imports[imp.Name.Name] = p
+			} else {
+				imports[importName(p)] = p
+			}
+		}
+		v, _ = l.imports.LoadOrStore(file, imports)
+	}
+
+	if r, ok := v.(map[string]string)[pkgName]; ok {
+		return r, nil
+	}
+	if pkgName == path.Base(path.Dir(file)) {
+		return ".", nil
+	}
+	return "", nil
+}
+
+func (l *Loader) resolvePackage(ctx context.Context, currentFile, pkgPath string) (
 	resolvedPath string, pkg *build.Package, err error,
 ) {
 	resolvedPath = pkgPath
-	pkg, err = resolvePackageWithFallback(pkgPath)
+	pkg, err = l.resolvePackageWithFallback(ctx, pkgPath)
 	if err != nil && currentFile != "" {
-		resolved, resolveErr := goutil.ResolveImport(currentFile, pkgPath)
+		resolved, resolveErr := l.resolveImport(currentFile, pkgPath)
 		if resolveErr != nil {
 			return "", nil, resolveErr
 		}
 		if resolved != "" {
 			resolvedPath = resolved
-			pkg, err = resolvePackageWithFallback(resolvedPath)
+			pkg, err = l.resolvePackageWithFallback(ctx, resolvedPath)
 		}
 	}
 	if err != nil {
@@ -367,15 +556,36 @@ func resolvePackage(currentFile, pkgPath string) (
 	return resolvedPath, pkg, nil
 }
 
-func getDecls(pkg *build.Package, pkgPath string) ([]declCache, error) {
-	// Try to load from cache.
-	decls, ok := declsCache[pkgPath]
-	if ok {
-		return decls, nil
+// getDecls gets all type and value declarations in pkg.
+//
+// The declarations of every package are only collected once, even when
+// called concurrently.
+func (l *Loader) getDecls(pkg *build.Package, pkgPath string) ([]declCache, error) {
+	l.mu.Lock()
+	e, ok := l.decls[pkgPath]
+	if !ok {
+		e = &declsEntry{dir: pkg.Dir}
+		l.decls[pkgPath] = e
+	}
+	l.mu.Unlock()
+
+	e.once.Do(func() { e.decls, e.err = l.collectDecls(pkg) })
+	if e.err != nil {
+		// Don't cache errors; the package may be fixed later.
+		l.mu.Lock()
+		if l.decls[pkgPath] == e {
+			delete(l.decls, pkgPath)
+		}
+		l.mu.Unlock()
 	}
+	return e.decls, e.err
+}
 
-	dbg("getDecls: parsing dir %#v: %#v", pkg.Dir, pkg.GoFiles)
+// collectDecls parses all files in pkg and collects the declarations.
+func (l *Loader) collectDecls(pkg *build.Package) ([]declCache, error) {
+	l.dbg("getDecls: parsing dir %#v: %#v", pkg.Dir, pkg.GoFiles)
 
+	var decls []declCache
 	type fileResult struct {
 		path    string
 		astFile *ast.File
@@ -385,17 +595,19 @@ func getDecls(pkg *build.Package, pkgPath string) ([]declCache, error) {
 	var wg sync.WaitGroup
 	for i, name := range pkg.GoFiles {
 		fullPath := filepath.Join(pkg.Dir, name)
-		if v, ok := astFileCache.Load(fullPath); ok {
-			results[i] = fileResult{path: fullPath, astFile: v.(*ast.File)}
+		if v, ok := l.files.Load(fullPath); ok {
+			results[i] = fileResult{path: fullPath, astFile: v.(cachedFile).ast}
 			continue
 		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			fset := token.NewFileSet()
-			f, parseErr := parser.ParseFile(fset, fullPath, nil, parser.ParseComments)
+			f, parseErr := l.disk.Load().parseDecls(fset, fullPath)
 			if f != nil && parseErr == nil {
-				astFileCache.Store(fullPath, f)
+				l.files.Store(fullPath, cachedFile{ast: f, tok: fset.File(f.FileStart)})
+			} else {
+				l.broken.Store(fullPath, struct{}{})
 			}
 			results[i] = fileResult{path: fullPath, astFile: f, err: parseErr}
 		}()
@@ -442,7 +654,6 @@ func getDecls(pkg *build.Package, pkgPath string) ([]declCache, error) {
 		}
 	}
 
-	declsCache[pkgPath] = decls
 	return decls, nil
 }
 
@@ -471,7 +682,11 @@ func (err ErrNotStruct) Error() string {
 //
 // A GetReference("Foo", "") call will add two entries to prog.References: Foo
 // and Bar (but only Foo is returned).
-func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath string) (*Reference, error) {
+func GetReference(prog *Program, refContext string, isEmbed bool, lookup, filePath string) (*Reference, error) {
+	return getReference(context.Background(), prog, refContext, isEmbed, lookup, filePath)
+}
+
+func getReference(ctx context.Context, prog *Program, refContext string, isEmbed bool, lookup, filePath string) (*Reference, error) {
 	wrapper := ""
 	isSlice := false
 	if strings.HasPrefix(lookup, "[") && strings.HasSuffix(lookup, "]") && strings.Contains(lookup, ":") {
@@ -484,9 +699,9 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 		lookup = lookup[2:]
 	}
 
-	dbg("getReference: lookup: %#v -> filepath: %#v", lookup, filePath)
+	prog.Loader.dbg("getReference: lookup: %#v -> filepath: %#v", lookup, filePath)
 	name, pkg := ParseLookup(lookup, filePath)
-	dbg("getReference: pkg: %#v -> name: %#v", pkg, name)
+	prog.Loader.dbg("getReference: pkg: %#v -> name: %#v", pkg, name)
 
 	// Already parsed this one, don't need to do it again.
 	if ref, ok := prog.References[lookup]; ok {
@@ -499,7 +714,7 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 	}
 
 	// Find type.
-	ts, foundPath, pkg, err := findType(filePath, pkg, name)
+	ts, foundPath, pkg, err := prog.Loader.findType(ctx, filePath, pkg, name)
 	if err != nil {
 		return nil, err
 	}
@@ -516,7 +731,7 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 		if wrapper != "" {
 			arLookup = fmt.Sprintf("[%v:%v]", wrapper, arLookup)
 		}
-		return GetReference(prog, context, isEmbed, arLookup, filePath)
+		return getReference(ctx, prog, refContext, isEmbed, arLookup, filePath)
 	case *ast.MapType:
 		st = &ast.StructType{Fields: &ast.FieldList{}}
 	default:
@@ -529,7 +744,7 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 		Package: pkg,
 		Lookup:  filepath.Base(pkg) + "." + name,
 		File:    foundPath,
-		Context: context,
+		Context: refContext,
 		IsEmbed: isEmbed,
 		IsSlice: isSlice,
 	}
@@ -542,12 +757,12 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 
 	var tagName string
 	switch ref.Context {
-	case ctxPath, ctxQuery, ctxForm:
+	case ctxPath, ctxQuery, ctxForm, ctxHeader, ctxCookie:
 		tagName = ref.Context
 	case ctxReq, ctxResp:
 		tagName = prog.Config.StructTag
 	default:
-		return nil, fmt.Errorf("invalid context: %q", context)
+		return nil, fmt.Errorf("invalid context: %q", refContext)
 	}
 
 	// Parse all the fields.
@@ -577,10 +792,10 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 
 			switch t := f.Type.(type) {
 			case *ast.Ident:
-				err = resolveType(prog, context, false, t, "", pkg)
+				err = resolveType(ctx, prog, refContext, false, t, "", pkg)
 			case *ast.StarExpr:
 				ex, _ := t.X.(*ast.Ident)
-				err = resolveType(prog, context, false, ex, "", pkg)
+				err = resolveType(ctx, prog, refContext, false, ex, "", pkg)
 			}
 
 			if err != nil {
@@ -660,7 +875,7 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 			}
 		}
 
-		nestLookup, err := findNested(prog, context, isEmbed, f, foundPath, pkg)
+		nestLookup, err := findNested(ctx, prog, refContext, isEmbed, f, foundPath, pkg)
 		if err != nil {
 			return nil, fmt.Errorf("\n  findNested: %v", err)
 		}
@@ -686,13 +901,13 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 	}
 
 	// Convert to JSON Schema.
-	schema, err := structToSchema(prog, name, tagName, ref)
+	schema, err := structToSchema(ctx, prog, name, tagName, ref)
 	if err != nil {
 		return nil, fmt.Errorf("%v can not be converted to JSON schema: %v", name, err)
 	}
 	ref.Schema = schema
 
-	if err := applyFieldWhitelists(prog, context, filePath, name, tagName, &ref); err != nil {
+	if err := applyFieldWhitelists(ctx, prog, refContext, filePath, name, tagName, &ref); err != nil {
 		return nil, err
 	}
 
@@ -733,7 +948,7 @@ func GetReference(prog *Program, context string, isEmbed bool, lookup, filePath
 	return &ref, nil
 }
 
-func applyFieldWhitelists(prog *Program, context, filePath, name, tagName string, ref *Reference) error {
+func applyFieldWhitelists(ctx context.Context, prog *Program, refContext, filePath, name, tagName string, ref *Reference) error {
 	changed := false
 	for _, p := range ref.Schema.Properties {
 		if len(p.FieldWhitelist) == 0 {
@@ -749,7 +964,7 @@ func applyFieldWhitelists(prog *Program, context, filePath, name, tagName string
 			if lookupStruct+f.Name != p.Reference {
 				continue
 			}
-			reference, err := GetReference(prog, context, false, lookupStruct+f.Name, filePath)
+			reference, err := getReference(ctx, prog, refContext, false, lookupStruct+f.Name, filePath)
 			if err != nil {
 				return fmt.Errorf("could not get referenced struct %s", lookupStruct+f.Name)
 			}
@@ -778,7 +993,7 @@ func applyFieldWhitelists(prog *Program, context, filePath, name, tagName string
 		}
 	}
 	if changed {
-		schema, err := structToSchema(prog, name, tagName, *ref)
+		schema, err := structToSchema(ctx, prog, name, tagName, *ref)
 		if err != nil {
 			return fmt.Errorf("%v can not be converted to JSON schema: %v", name, err)
 		}
@@ -787,7 +1002,7 @@ func applyFieldWhitelists(prog *Program, context, filePath, name, tagName string
 	return nil
 }
 
-func findNested(prog *Program, context string, isEmbed bool, f *ast.Field, filePath, pkg string) (string, error) {
+func findNested(ctx context.Context, prog *Program, refContext string, isEmbed bool, f *ast.Field, filePath, pkg string) (string, error) {
 	var name *ast.Ident
 
 	sw := f.Type
@@ -887,7 +1102,7 @@ start:
 		return lookup, nil
 	}
 	if _, ok := prog.References[lookup]; !ok {
-		err := resolveType(prog, context, isEmbed, name, filePath, pkg)
+		err := resolveType(ctx, prog, refContext, isEmbed, name, filePath, pkg)
 		if err != nil {
 			return "", fmt.Errorf("%v.%v: %v", pkg, name, err)
 		}
@@ -896,11 +1111,11 @@ start:
 }
 
 // Add the type declaration to references.
-func resolveType(prog *Program, context string, isEmbed bool, typ *ast.Ident, filePath, pkg string) error {
+func resolveType(ctx context.Context, prog *Program, refContext string, isEmbed bool, typ *ast.Ident, filePath, pkg string) error {
 	var ts *ast.TypeSpec
 	if typ.Obj == nil {
 		var err error
-		ts, _, _, err = findType(filePath, pkg, typ.Name)
+		ts, _, _, err = prog.Loader.findType(ctx, filePath, pkg, typ.Name)
 		if err != nil {
 			return err
 		}
@@ -922,7 +1137,7 @@ func resolveType(prog *Program, context string, isEmbed bool, typ *ast.Ident, fi
 
 	// This sets prog.References
 	lookup := pkg + "." + typ.Name
-	_, err := GetReference(prog, context, isEmbed, lookup, filePath)
+	_, err := getReference(ctx, prog, refContext, isEmbed, lookup, filePath)
 	return err
 }
 
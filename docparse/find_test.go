@@ -1,12 +1,15 @@
 package docparse
 
 import (
+	"context"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/teamwork/test"
@@ -33,9 +36,107 @@ func TestExampleDir(t *testing.T) {
 	}
 }
 
+func TestParsePrefix(t *testing.T) {
+	for _, routes := range []bool{false, true} {
+		prog := NewProgram(false)
+		prog.Config.Packages = []string{"./testdata/src/lint"}
+		prog.Config.StructTag = "json"
+		prog.Config.Prefix = "/v1"
+		prog.Config.Routes = routes
+		_ = Parse(prog)
+
+		if len(prog.Endpoints) == 0 {
+			t.Fatal("no endpoints")
+		}
+		for _, e := range prog.Endpoints {
+			if has := strings.HasPrefix(e.Path, "/v1/"); has == routes {
+				t.Errorf("routes=%t: wrong path %q", routes, e.Path)
+			}
+		}
+	}
+}
+
+func TestFindCommentsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	prog := NewProgram(false)
+	prog.Config.Packages = []string{"../example"}
+	prog.Config.StructTag = "json"
+	prog.Config.Output = func(io.Writer, *Program) error {
+		t.Error("output called")
+		return nil
+	}
+
+	err := FindCommentsContext(ctx, io.Discard, prog)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if len(prog.Endpoints) > 0 {
+		t.Errorf("len(prog.Endpoints) == %d", len(prog.Endpoints))
+	}
+}
+
+// cancelAfter is a context which is cancelled after Err() is called n times.
+type cancelAfter struct {
+	context.Context
+	n, calls atomic.Int32
+}
+
+func (c *cancelAfter) Err() error {
+	if c.calls.Add(1) > c.n.Load() {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestParseContextCancel(t *testing.T) {
+	newProg := func(cacheDir string) *Program {
+		prog := NewProgram(false)
+		prog.Config.Packages = []string{"./testdata/src/alias"}
+		prog.Config.StructTag = "json"
+		prog.Loader.SetCacheDir(cacheDir)
+		return prog
+	}
+
+	// Fill the on-disk cache, so the package list isn't loaded with
+	// packages.Load every time.
+	cacheDir := t.TempDir()
+	if err := Parse(newProg(cacheDir)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancel on every call to Err() until it's parsed without being
+	// cancelled; at least one of these is after some references were
+	// resolved.
+	var midParse bool
+	for n := int32(0); ; n++ {
+		ctx := &cancelAfter{Context: context.Background()}
+		ctx.n.Store(n)
+		prog := newProg(cacheDir)
+		err := ParseContext(ctx, prog)
+		if ctx.calls.Load() <= n {
+			if err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("n=%d: wrong error: %v", n, err)
+		}
+		if len(prog.References) > 0 {
+			midParse = true
+		}
+	}
+	if !midParse {
+		t.Error("never cancelled after resolving references")
+	}
+}
+
 func TestFindType(t *testing.T) {
+	l := NewLoader()
 	t.Run("absolute", func(t *testing.T) {
-		ts, path, pkg, err := findType("", "net/http", "Header")
+		ts, path, pkg, err := l.findType(context.Background(), "", "net/http", "Header")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -52,17 +153,18 @@ func TestFindType(t *testing.T) {
 			t.Fatalf("path == %v", path)
 		}
 
-		p, ok := declsCache["net/http"]
+		e, ok := l.decls["net/http"]
 		if !ok {
 			t.Fatal("not stored in cache?")
 		}
+		p := e.decls
 
 		if len(p) < 100 {
 			t.Errorf("len(p) == %v", len(p))
 		}
 
 		// Make sure it works from cache as well.
-		tsCached, pathCached, pkgCached, err := findType("", "net/http", "Header")
+		tsCached, pathCached, pkgCached, err := l.findType(context.Background(), "", "net/http", "Header")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -78,7 +180,7 @@ func TestFindType(t *testing.T) {
 	})
 
 	t.Run("relative", func(t *testing.T) {
-		ts, path, pkg, err := findType("../example/example.go", "exampleimport", "Foo")
+		ts, path, pkg, err := l.findType(context.Background(), "../example/example.go", "exampleimport", "Foo")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -119,7 +221,7 @@ func TestFindType(t *testing.T) {
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-				_, _, _, err := findType(tt.inFile, tt.inPkgPath, tt.inName)
+				_, _, _, err := l.findType(context.Background(), tt.inFile, tt.inPkgPath, tt.inName)
 				if !test.ErrorContains(err, tt.wantErr) {
 					t.Fatalf("\nwant: %v\ngot:  %v", tt.wantErr, err)
 				}
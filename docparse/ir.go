@@ -0,0 +1,536 @@
+package docparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IRVersion is the version of the format written by WriteIR; it's increased on
+// incompatible changes.
+const IRVersion = 1
+
+func init() {
+	RegisterOutput("ir-json", "Kommentaar intermediate representation, for LoadIR", WriteIR)
+}
+
+// The intermediate representation is a copy of the Program with JSON tags, so
+// that changes to the Go types don't change the format by accident.
+type (
+	irProgram struct {
+		Version    int                    `json:"version"`
+		Config     irConfig               `json:"config"`
+		Endpoints  []irEndpoint           `json:"endpoints"`
+		References map[string]irReference `json:"references"`
+	}
+
+	// Only the configuration used by output functions is stored; Prefix and
+	// Audience are applied to the endpoints already.
+	irConfig struct {
+		Title             string                      `json:"title,omitempty"`
+		Description       string                      `json:"description,omitempty"`
+		Version           string                      `json:"version,omitempty"`
+		ContactName       string                      `json:"contactName,omitempty"`
+		ContactEmail      string                      `json:"contactEmail,omitempty"`
+		ContactSite       string                      `json:"contactSite,omitempty"`
+		DefaultRequestCt  string                      `json:"defaultRequestCt,omitempty"`
+		DefaultResponseCt string                      `json:"defaultResponseCt,omitempty"`
+		DefaultResponse   map[int]irResponse          `json:"defaultResponse,omitempty"`
+		Basepath          string                      `json:"basepath,omitempty"`
+		StructTag         string                      `json:"structTag,omitempty"`
+		SecurityScheme    map[string]irSecurityScheme `json:"securityScheme,omitempty"`
+		DefaultSecurity   []irSecurityRequirement     `json:"defaultSecurity,omitempty"`
+	}
+
+	irEndpoint struct {
+		Method           string                  `json:"method"`
+		Path             string                  `json:"path"`
+		Tags             []string                `json:"tags,omitempty"`
+		Tagline          string                  `json:"tagline,omitempty"`
+		Info             string                  `json:"info,omitempty"`
+		Request          irRequest               `json:"request"`
+		Responses        map[int]irResponse      `json:"responses"`
+		Extend           map[string]interface{}  `json:"extend,omitempty"`
+		Pos              irPosition              `json:"pos"`
+		End              irPosition              `json:"end"`
+		Security         []irSecurityRequirement `json:"security"` // null is the default, [] is none.
+		Deprecated       bool                    `json:"deprecated,omitempty"`
+		DeprecatedReason string                  `json:"deprecatedReason,omitempty"`
+		Audience         []string                `json:"audience,omitempty"`
+	}
+
+	irPosition struct {
+		File   string `json:"file,omitempty"`
+		Offset int    `json:"offset,omitempty"`
+		Line   int    `json:"line,omitempty"`
+		Column int    `json:"column,omitempty"`
+	}
+
+	irRequest struct {
+		ContentType string      `json:"contentType,omitempty"`
+		Body        *irRef      `json:"body,omitempty"`
+		Path        *irRef      `json:"path,omitempty"`
+		Query       *irRef      `json:"query,omitempty"`
+		Form        *irRef      `json:"form,omitempty"`
+		Header      *irRef      `json:"header,omitempty"`
+		Cookie      *irRef      `json:"cookie,omitempty"`
+		Example     interface{} `json:"example,omitempty"`
+	}
+
+	irResponse struct {
+		ContentType string      `json:"contentType,omitempty"`
+		Body        *irRef      `json:"body,omitempty"`
+		Headers     *irRef      `json:"headers,omitempty"`
+		Keyword     string      `json:"keyword,omitempty"`
+		Example     interface{} `json:"example,omitempty"`
+	}
+
+	irRef struct {
+		Description string `json:"description,omitempty"`
+		Reference   string `json:"reference,omitempty"`
+	}
+
+	irReference struct {
+		Name    string    `json:"name"`
+		Package string    `json:"package"`
+		File    string    `json:"file,omitempty"`
+		Lookup  string    `json:"lookup"`
+		Info    string    `json:"info,omitempty"`
+		Context string    `json:"context"`
+		IsEmbed bool      `json:"isEmbed,omitempty"`
+		IsSlice bool      `json:"isSlice,omitempty"`
+		Wrapper string    `json:"wrapper,omitempty"`
+		Schema  *irSchema `json:"schema,omitempty"`
+		Fields  []irField `json:"fields,omitempty"`
+	}
+
+	// irField is a struct field; the type is stored as Go syntax.
+	irField struct {
+		Name string `json:"name"`
+		Type string `json:"type,omitempty"`
+		Tag  string `json:"tag,omitempty"`
+	}
+
+	irSchema struct {
+		Reference            string               `json:"ref,omitempty"`
+		Title                string               `json:"title,omitempty"`
+		Description          string               `json:"description,omitempty"`
+		Type                 string               `json:"type,omitempty"`
+		Enum                 []string             `json:"enum,omitempty"`
+		Format               string               `json:"format,omitempty"`
+		Required             []string             `json:"required,omitempty"`
+		Default              string               `json:"default,omitempty"`
+		Minimum              int                  `json:"minimum,omitempty"`
+		Maximum              int                  `json:"maximum,omitempty"`
+		Readonly             *bool                `json:"readonly,omitempty"`
+		Deprecated           bool                 `json:"deprecated,omitempty"`
+		Example              interface{}          `json:"example,omitempty"`
+		FieldWhitelist       []string             `json:"fieldWhitelist,omitempty"`
+		Items                *irSchema            `json:"items,omitempty"`
+		Properties           map[string]*irSchema `json:"properties,omitempty"`
+		AdditionalProperties *irSchema            `json:"additionalProperties,omitempty"`
+		OmitDoc              bool                 `json:"omitdoc,omitempty"`
+		Internal             bool                 `json:"internal,omitempty"`
+		CustomSchema         string               `json:"customSchema,omitempty"`
+		Nullable             bool                 `json:"nullable,omitempty"`
+	}
+
+	irSecurityScheme struct {
+		Type             string   `json:"type"`
+		In               string   `json:"in,omitempty"`
+		Name             string   `json:"name,omitempty"`
+		BearerFormat     string   `json:"bearerFormat,omitempty"`
+		Flow             string   `json:"flow,omitempty"`
+		AuthorizationURL string   `json:"authorizationURL,omitempty"`
+		TokenURL         string   `json:"tokenURL,omitempty"`
+		Scopes           []string `json:"scopes,omitempty"`
+	}
+
+	irSecurityRequirement struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes,omitempty"`
+	}
+)
+
+// WriteIR writes prog as JSON, in a format which can be read with LoadIR.
+//
+// The format is versioned with IRVersion, and can also be read by other
+// tools; the fields are the same as in Program.
+func WriteIR(w io.Writer, prog *Program) error {
+	out := irProgram{
+		Version: IRVersion,
+		Config: irConfig{
+			Title:             prog.Config.Title,
+			Description:       string(prog.Config.Description),
+			Version:           prog.Config.Version,
+			ContactName:       prog.Config.ContactName,
+			ContactEmail:      prog.Config.ContactEmail,
+			ContactSite:       prog.Config.ContactSite,
+			DefaultRequestCt:  prog.Config.DefaultRequestCt,
+			DefaultResponseCt: prog.Config.DefaultResponseCt,
+			Basepath:          prog.Config.Basepath,
+			StructTag:         prog.Config.StructTag,
+			DefaultSecurity:   toIRSecurity(prog.Config.DefaultSecurity),
+		},
+		Endpoints:  make([]irEndpoint, 0, len(prog.Endpoints)),
+		References: make(map[string]irReference, len(prog.References)),
+	}
+	if prog.Config.DefaultResponse != nil {
+		out.Config.DefaultResponse = make(map[int]irResponse, len(prog.Config.DefaultResponse))
+		for code, r := range prog.Config.DefaultResponse {
+			out.Config.DefaultResponse[code] = toIRResponse(r)
+		}
+	}
+	if prog.Config.SecurityScheme != nil {
+		out.Config.SecurityScheme = make(map[string]irSecurityScheme, len(prog.Config.SecurityScheme))
+		for name, s := range prog.Config.SecurityScheme {
+			out.Config.SecurityScheme[name] = irSecurityScheme(s)
+		}
+	}
+
+	for _, e := range prog.Endpoints {
+		ie := irEndpoint{
+			Method:  e.Method,
+			Path:    e.Path,
+			Tags:    e.Tags,
+			Tagline: e.Tagline,
+			Info:    e.Info,
+			Request: irRequest{
+				ContentType: e.Request.ContentType,
+				Body:        toIRRef(e.Request.Body),
+				Path:        toIRRef(e.Request.Path),
+				Query:       toIRRef(e.Request.Query),
+				Form:        toIRRef(e.Request.Form),
+				Header:      toIRRef(e.Request.Header),
+				Cookie:      toIRRef(e.Request.Cookie),
+				Example:     e.Request.Example,
+			},
+			Responses:        make(map[int]irResponse, len(e.Responses)),
+			Extend:           e.Extend,
+			Pos:              toIRPosition(e.Pos),
+			End:              toIRPosition(e.End),
+			Security:         toIRSecurity(e.Security),
+			Deprecated:       e.Deprecated,
+			DeprecatedReason: e.DeprecatedReason,
+			Audience:         e.Audience,
+		}
+		for code, r := range e.Responses {
+			ie.Responses[code] = toIRResponse(r)
+		}
+		out.Endpoints = append(out.Endpoints, ie)
+	}
+
+	for k, ref := range prog.References {
+		ir := irReference{
+			Name:    ref.Name,
+			Package: ref.Package,
+			File:    ref.File,
+			Lookup:  ref.Lookup,
+			Info:    ref.Info,
+			Context: ref.Context,
+			IsEmbed: ref.IsEmbed,
+			IsSlice: ref.IsSlice,
+			Wrapper: ref.Wrapper,
+			Schema:  toIRSchema(ref.Schema),
+		}
+		for _, f := range ref.Fields {
+			field, err := toIRField(f)
+			if err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			ir.Fields = append(ir.Fields, field)
+		}
+		out.References[k] = ir
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// LoadIR reads a Program written by WriteIR.
+//
+// The Program can be passed to any output function; Config.Output is not set,
+// and Config.Transforms aren't run again.
+func LoadIR(r io.Reader) (*Program, error) {
+	var in irProgram
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("LoadIR: %v", err)
+	}
+	if in.Version != IRVersion {
+		return nil, fmt.Errorf("LoadIR: unsupported version %d; expected %d", in.Version, IRVersion)
+	}
+
+	prog := &Program{
+		Loader: NewLoader(),
+		Config: Config{
+			Title:             in.Config.Title,
+			Description:       template.HTML(in.Config.Description),
+			Version:           in.Config.Version,
+			ContactName:       in.Config.ContactName,
+			ContactEmail:      in.Config.ContactEmail,
+			ContactSite:       in.Config.ContactSite,
+			DefaultRequestCt:  in.Config.DefaultRequestCt,
+			DefaultResponseCt: in.Config.DefaultResponseCt,
+			Basepath:          in.Config.Basepath,
+			StructTag:         in.Config.StructTag,
+			MapTypes:          make(map[string]string),
+			MapFormats:        make(map[string]string),
+			DefaultSecurity:   fromIRSecurity(in.Config.DefaultSecurity),
+		},
+		Endpoints:  make([]*Endpoint, 0, len(in.Endpoints)),
+		References: make(map[string]Reference, len(in.References)),
+	}
+	if in.Config.DefaultResponse != nil {
+		prog.Config.DefaultResponse = make(map[int]Response, len(in.Config.DefaultResponse))
+		for code, r := range in.Config.DefaultResponse {
+			prog.Config.DefaultResponse[code] = fromIRResponse(r)
+		}
+	}
+	if in.Config.SecurityScheme != nil {
+		prog.Config.SecurityScheme = make(map[string]SecurityScheme, len(in.Config.SecurityScheme))
+		for name, s := range in.Config.SecurityScheme {
+			prog.Config.SecurityScheme[name] = SecurityScheme(s)
+		}
+	}
+
+	for _, ie := range in.Endpoints {
+		e := &Endpoint{
+			Method:  ie.Method,
+			Path:    ie.Path,
+			Tags:    ie.Tags,
+			Tagline: ie.Tagline,
+			Info:    ie.Info,
+			Request: Request{
+				ContentType: ie.Request.ContentType,
+				Body:        fromIRRef(ie.Request.Body),
+				Path:        fromIRRef(ie.Request.Path),
+				Query:       fromIRRef(ie.Request.Query),
+				Form:        fromIRRef(ie.Request.Form),
+				Header:      fromIRRef(ie.Request.Header),
+				Cookie:      fromIRRef(ie.Request.Cookie),
+				Example:     ie.Request.Example,
+			},
+			Responses:        make(map[int]Response, len(ie.Responses)),
+			Extend:           ie.Extend,
+			Pos:              fromIRPosition(ie.Pos),
+			End:              fromIRPosition(ie.End),
+			Security:         fromIRSecurity(ie.Security),
+			Deprecated:       ie.Deprecated,
+			DeprecatedReason: ie.DeprecatedReason,
+			Audience:         ie.Audience,
+		}
+		for code, r := range ie.Responses {
+			e.Responses[code] = fromIRResponse(r)
+		}
+		prog.Endpoints = append(prog.Endpoints, e)
+	}
+
+	for k, ir := range in.References {
+		ref := Reference{
+			Name:    ir.Name,
+			Package: ir.Package,
+			File:    ir.File,
+			Lookup:  ir.Lookup,
+			Info:    ir.Info,
+			Context: ir.Context,
+			IsEmbed: ir.IsEmbed,
+			IsSlice: ir.IsSlice,
+			Wrapper: ir.Wrapper,
+			Schema:  fromIRSchema(ir.Schema),
+		}
+		for _, f := range ir.Fields {
+			p, err := fromIRField(f)
+			if err != nil {
+				return nil, fmt.Errorf("LoadIR: %s: %v", k, err)
+			}
+			ref.Fields = append(ref.Fields, p)
+		}
+		prog.References[k] = ref
+	}
+
+	return prog, nil
+}
+
+func toIRRef(r *Ref) *irRef {
+	if r == nil {
+		return nil
+	}
+	return &irRef{Description: r.Description, Reference: r.Reference}
+}
+
+func fromIRRef(r *irRef) *Ref {
+	if r == nil {
+		return nil
+	}
+	return &Ref{Description: r.Description, Reference: r.Reference}
+}
+
+func toIRResponse(r Response) irResponse {
+	return irResponse{
+		ContentType: r.ContentType,
+		Body:        toIRRef(r.Body),
+		Headers:     toIRRef(r.Headers),
+		Keyword:     r.Keyword,
+		Example:     r.Example,
+	}
+}
+
+func fromIRResponse(r irResponse) Response {
+	return Response{
+		ContentType: r.ContentType,
+		Body:        fromIRRef(r.Body),
+		Headers:     fromIRRef(r.Headers),
+		Keyword:     r.Keyword,
+		Example:     r.Example,
+	}
+}
+
+func toIRPosition(p token.Position) irPosition {
+	return irPosition{File: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func fromIRPosition(p irPosition) token.Position {
+	return token.Position{Filename: p.File, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+// toIRSecurity and fromIRSecurity keep the difference between nil and an empty
+// slice.
+func toIRSecurity(s []SecurityRequirement) []irSecurityRequirement {
+	if s == nil {
+		return nil
+	}
+	out := make([]irSecurityRequirement, 0, len(s))
+	for _, r := range s {
+		out = append(out, irSecurityRequirement(r))
+	}
+	return out
+}
+
+func fromIRSecurity(s []irSecurityRequirement) []SecurityRequirement {
+	if s == nil {
+		return nil
+	}
+	out := make([]SecurityRequirement, 0, len(s))
+	for _, r := range s {
+		out = append(out, SecurityRequirement(r))
+	}
+	return out
+}
+
+func toIRSchema(s *Schema) *irSchema {
+	if s == nil {
+		return nil
+	}
+	out := &irSchema{
+		Reference:            s.Reference,
+		Title:                s.Title,
+		Description:          s.Description,
+		Type:                 s.Type,
+		Enum:                 s.Enum,
+		Format:               s.Format,
+		Required:             s.Required,
+		Default:              s.Default,
+		Minimum:              s.Minimum,
+		Maximum:              s.Maximum,
+		Readonly:             s.Readonly,
+		Deprecated:           s.Deprecated,
+		Example:              s.Example,
+		FieldWhitelist:       s.FieldWhitelist,
+		Items:                toIRSchema(s.Items),
+		AdditionalProperties: toIRSchema(s.AdditionalProperties),
+		OmitDoc:              s.OmitDoc,
+		Internal:             s.Internal,
+		CustomSchema:         s.CustomSchema,
+		Nullable:             s.Nullable,
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*irSchema, len(s.Properties))
+		for k, p := range s.Properties {
+			out.Properties[k] = toIRSchema(p)
+		}
+	}
+	return out
+}
+
+func fromIRSchema(s *irSchema) *Schema {
+	if s == nil {
+		return nil
+	}
+	out := &Schema{
+		Reference:            s.Reference,
+		Title:                s.Title,
+		Description:          s.Description,
+		Type:                 s.Type,
+		Enum:                 s.Enum,
+		Format:               s.Format,
+		Required:             s.Required,
+		Default:              s.Default,
+		Minimum:              s.Minimum,
+		Maximum:              s.Maximum,
+		Readonly:             s.Readonly,
+		Deprecated:           s.Deprecated,
+		Example:              s.Example,
+		FieldWhitelist:       s.FieldWhitelist,
+		Items:                fromIRSchema(s.Items),
+		AdditionalProperties: fromIRSchema(s.AdditionalProperties),
+		OmitDoc:              s.OmitDoc,
+		Internal:             s.Internal,
+		CustomSchema:         s.CustomSchema,
+		Nullable:             s.Nullable,
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*Schema, len(s.Properties))
+		for k, p := range s.Properties {
+			out.Properties[k] = fromIRSchema(p)
+		}
+	}
+	return out
+}
+
+// toIRField stores the name, type, and struct tag of the KindField; this is
+// all the output functions need.
+func toIRField(p Param) (irField, error) {
+	f := irField{Name: p.Name}
+	if p.KindField == nil {
+		return f, nil
+	}
+	if p.KindField.Type != nil {
+		f.Type = types.ExprString(p.KindField.Type)
+	}
+	if p.KindField.Tag != nil {
+		tag, err := strconv.Unquote(p.KindField.Tag.Value)
+		if err != nil {
+			return f, fmt.Errorf("field %s: invalid struct tag %s", p.Name, p.KindField.Tag.Value)
+		}
+		f.Tag = tag
+	}
+	return f, nil
+}
+
+// fromIRField creates a new ast.Field for f.
+func fromIRField(f irField) (Param, error) {
+	field := &ast.Field{Names: []*ast.Ident{ast.NewIdent(f.Name)}}
+	if f.Type != "" {
+		typ, err := parser.ParseExpr(f.Type)
+		if err != nil {
+			return Param{}, fmt.Errorf("field %s: invalid type %q: %v", f.Name, f.Type, err)
+		}
+		field.Type = typ
+	}
+	if f.Tag != "" {
+		tag := "`" + f.Tag + "`"
+		if strings.Contains(f.Tag, "`") {
+			tag = strconv.Quote(f.Tag)
+		}
+		field.Tag = &ast.BasicLit{Kind: token.STRING, Value: tag}
+	}
+	return Param{Name: f.Name, KindField: field}, nil
+}
@@ -0,0 +1,63 @@
+package docparse
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/teamwork/test"
+	"github.com/teamwork/utils/v2/goutil"
+)
+
+func TestLoadIR(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.StructTag = "json"
+	prog.Config.Packages = []string{"./testdata/src/audience"}
+	prog.Config.Output = WriteIR
+	buf := new(bytes.Buffer)
+	if err := FindComments(buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	prog.Endpoints[0].Security = []SecurityRequirement{}
+
+	buf.Reset()
+	if err := WriteIR(buf, prog); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadIR(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Endpoints) != 3 {
+		t.Fatalf("wrong number of endpoints: %d", len(loaded.Endpoints))
+	}
+	if s := loaded.Endpoints[0].Security; s == nil || len(s) != 0 {
+		t.Errorf("empty security not kept: %#v", s)
+	}
+	if s := loaded.Endpoints[1].Security; s != nil {
+		t.Errorf("nil security not kept: %#v", s)
+	}
+	if a := loaded.Endpoints[1].Audience; strings.Join(a, " ") != "internal partner" {
+		t.Errorf("wrong audience: %v", a)
+	}
+	if p := loaded.Endpoints[1].Pos; !strings.HasSuffix(p.Filename, "audience.go") || p.Line == 0 {
+		t.Errorf("wrong position: %v", p)
+	}
+
+	ref := loaded.References["audience.queryParams"]
+	if !ref.Schema.Properties["debug"].Internal {
+		t.Error("{internal} not kept")
+	}
+	if len(ref.Fields) != 2 {
+		t.Fatalf("wrong fields: %#v", ref.Fields)
+	}
+	if name := goutil.TagName(ref.Fields[1].KindField, "query"); name != "debug" {
+		t.Errorf("wrong tag name: %q", name)
+	}
+
+	_, err = LoadIR(strings.NewReader(`{"version": 9999}`))
+	if !test.ErrorContains(err, "unsupported version 9999") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package docparse
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"go/ast"
@@ -29,6 +30,13 @@ type Schema struct {
 	Maximum     int      `json:"maximum,omitempty" yaml:"maximum,omitempty"`
 	Readonly    *bool    `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
 
+	// Swagger 2 only supports deprecated on operations.
+	Deprecated bool `json:"x-deprecated,omitempty" yaml:"x-deprecated,omitempty"`
+
+	// Example value, with the JSON type of the schema; set from the {example}
+	// property or the example struct tag.
+	Example interface{} `json:"example,omitempty" yaml:"example,omitempty"`
+
 	FieldWhitelist []string `json:"field-whitelist,omitempty" yaml:"field-whitelist,omitempty"`
 
 	// Store array items; for primitives:
@@ -44,11 +52,16 @@ type Schema struct {
 	AdditionalProperties *Schema `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
 
 	OmitDoc      bool   `json:"-" yaml:"-"` // {omitdoc}
+	Internal     bool   `json:"-" yaml:"-"` // {internal}
 	CustomSchema string `json:"-" yaml:"-"` // {schema: path}
+
+	// Nullable is set for pointer fields. Swagger 2 has no way to express
+	// this, so it's only used by output formats that can.
+	Nullable bool `json:"-" yaml:"-"`
 }
 
 // Convert a struct to a JSON schema.
-func structToSchema(prog *Program, name, tagName string, ref Reference) (*Schema, error) {
+func structToSchema(ctx context.Context, prog *Program, name, tagName string, ref Reference) (*Schema, error) {
 	schema := &Schema{
 		Title:       name,
 		Description: ref.Info,
@@ -71,12 +84,12 @@ func structToSchema(prog *Program, name, tagName string, ref Reference) (*Schema
 			name = p.Name
 		}
 
-		prop, err := fieldToSchema(prog, name, tagName, ref, p.KindField, nil)
+		prop, err := fieldToSchema(ctx, prog, name, tagName, ref, p.KindField, nil)
 		if err != nil {
 			return nil, fmt.Errorf("cannot parse %v: %v", ref.Lookup, err)
 		}
 
-		if !sliceutil.Contains([]string{"path", "query", "form"}, ref.Context) {
+		if !sliceutil.Contains([]string{"path", "query", "form", "header", "cookie"}, ref.Context) {
 			fixRequired(schema, prop)
 
 			if prog.Config.InferRequired && isInferredRequired(p.KindField, tagName) &&
@@ -143,16 +156,34 @@ func fixRequired(parent *Schema, prop *Schema) {
 	}
 }
 
+// movedRequired gets a copy of p with the required tags moved to the parent,
+// like fixRequired does. This is needed to validate examples before the field
+// is added to the parent.
+func movedRequired(p *Schema) *Schema {
+	cp := *p
+	cp.Required = nil
+	if p.Properties != nil {
+		cp.Properties = make(map[string]*Schema, len(p.Properties))
+		for k, prop := range p.Properties {
+			cp.Required = append(cp.Required, prop.Required...)
+			cp.Properties[k] = movedRequired(prop)
+		}
+	}
+	return &cp
+}
+
 const (
-	paramRequired  = "required"
-	paramOptional  = "optional"
-	paramOmitEmpty = "omitempty"
-	paramReadOnly  = "readonly"
-	paramOmitDoc   = "omitdoc"
-	paramEnum      = "enum"
+	paramRequired   = "required"
+	paramOptional   = "optional"
+	paramOmitEmpty  = "omitempty"
+	paramReadOnly   = "readonly"
+	paramOmitDoc    = "omitdoc"
+	paramEnum       = "enum"
+	paramDeprecated = "deprecated"
+	paramInternal   = "internal"
 )
 
-func setTags(name, fName string, p *Schema, tags []string) error {
+func setTags(prog *Program, name, fName string, p *Schema, tags []string) error {
 	for _, t := range tags {
 		switch t {
 
@@ -172,6 +203,10 @@ func setTags(name, fName string, p *Schema, tags []string) error {
 		case paramEnum:
 			// For this type of enum, we figure out the variations based on the type.
 			p.Type = "enum"
+		case paramDeprecated:
+			p.Deprecated = true
+		case paramInternal:
+			p.Internal = true
 
 		// Various string formats.
 		// https://tools.ietf.org/html/draft-handrews-json-schema-validation-01#section-7.3
@@ -207,6 +242,10 @@ func setTags(name, fName string, p *Schema, tags []string) error {
 			case strings.HasPrefix(t, "default: "):
 				p.Default = strings.TrimSpace(t[8:])
 
+			case strings.HasPrefix(t, "example: "):
+				// Converted to the correct type in fieldToSchema().
+				p.Example = strings.TrimSpace(strings.TrimPrefix(t, "example: "))
+
 			case strings.HasPrefix(t, "range: "):
 				rng := strings.Split(t[6:], "-")
 				if len(rng) != 2 {
@@ -232,7 +271,7 @@ func setTags(name, fName string, p *Schema, tags []string) error {
 
 			case strings.HasPrefix(t, "schema: "):
 				p.CustomSchema = filepath.Join(filepath.Dir(fName), t[8:])
-				err := readAndUnmarshalSchemaFile(p.CustomSchema, p)
+				err := prog.Loader.readSchemaFile(p.CustomSchema, p)
 				if err != nil {
 					return fmt.Errorf("custom schema: %v", err)
 				}
@@ -273,6 +312,93 @@ func extractGenericIdent(x ast.Expr, curPkg string) (*ast.Ident, string, error)
 
 // Convert a struct field to JSON schema.
 func fieldToSchema(
+	ctx context.Context,
+	prog *Program,
+	fName, tagName string,
+	ref Reference,
+	f *ast.Field,
+	generics map[string]string,
+) (*Schema, error) {
+	p, err := fieldTypeToSchema(ctx, prog, fName, tagName, ref, f, generics)
+	if err != nil || p == nil {
+		return p, err
+	}
+
+	// The example can only be converted once we know the type.
+	if p.CustomSchema != "" {
+		return p, nil
+	}
+	ex, ok := p.Example.(string)
+	if !ok && f.Tag != nil {
+		ex, ok = reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Lookup("example")
+	}
+	if ok {
+		p.Example, err = typedExample(prog, p, ex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid example for %q: %v", fName, err)
+		}
+	}
+
+	return p, nil
+}
+
+// isDeprecated reports if the documentation has a paragraph starting with
+// "Deprecated:", as is the Go convention.
+func isDeprecated(doc string) bool {
+	for _, para := range strings.Split(doc, "\n\n") {
+		if strings.HasPrefix(strings.TrimSpace(para), "Deprecated:") {
+			return true
+		}
+	}
+	return false
+}
+
+// typedExample converts the example value v to the type of the schema p.
+// Arrays are given as a list of space-separated values and objects as JSON.
+func typedExample(prog *Program, p *Schema, v string) (interface{}, error) {
+	if p == nil {
+		return v, nil
+	}
+	if p.Reference != "" || p.Type == "object" ||
+		p.Type == "array" && strings.HasPrefix(v, "[") {
+		var ex interface{}
+		if err := json.Unmarshal([]byte(v), &ex); err != nil {
+			return nil, err
+		}
+		return ex, validateExample(prog, ex, movedRequired(p), "")
+	}
+
+	if len(p.Enum) > 0 && p.Type != "array" && !sliceutil.Contains(p.Enum, v) {
+		return nil, fmt.Errorf("%q is not one of %s", v, strings.Join(p.Enum, ", "))
+	}
+
+	switch p.Type {
+	case "array":
+		var (
+			items = strings.Fields(v)
+			ex    = make([]interface{}, 0, len(items))
+		)
+		for _, item := range items {
+			i, err := typedExample(prog, p.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			ex = append(ex, i)
+		}
+		return ex, nil
+	case "integer":
+		return strconv.ParseInt(v, 10, 64)
+	case "number":
+		return strconv.ParseFloat(v, 64)
+	case "boolean":
+		return strconv.ParseBool(v)
+	}
+	return v, nil
+}
+
+// fieldTypeToSchema converts the struct field's type to JSON schema.
+func fieldTypeToSchema(
+	ctx context.Context,
 	prog *Program,
 	fName, tagName string,
 	ref Reference,
@@ -290,10 +416,13 @@ func fieldToSchema(
 
 	var tags []string
 	p.Description, tags = parseTags(p.Description)
-	err := setTags(fName, ref.File, &p, tags)
+	err := setTags(prog, fName, ref.File, &p, tags)
 	if err != nil {
 		return nil, err
 	}
+	if isDeprecated(p.Description) {
+		p.Deprecated = true
+	}
 
 	// Don't need to carry on if we're loading our own schema.
 	if p.CustomSchema != "" {
@@ -303,7 +432,7 @@ func fieldToSchema(
 	pkg := ref.Package
 	var name *ast.Ident
 
-	dbg("fieldToSchema: %v", f.Names)
+	prog.Loader.dbg("fieldToSchema: %v", f.Names)
 
 	sw := f.Type
 start:
@@ -326,16 +455,16 @@ start:
 			name = typ.Sel
 
 			lookup := pkg + "." + name.Name
-			if _, err := GetReference(prog, ref.Context, false, lookup, ref.File); err != nil {
+			if _, err := getReference(ctx, prog, ref.Context, false, lookup, ref.File); err != nil {
 				return nil, fmt.Errorf("GetReference: %v", err)
 			}
 		case *ast.Ident:
 			name = typ
 		}
 
-	// Pointer type; we don't really care about this for now, so just read over
-	// it.
+	// Pointer type; the only thing we care about is that it can be null.
 	case *ast.StarExpr:
+		p.Nullable = true
 		sw = typ.X
 		goto start
 
@@ -369,12 +498,12 @@ start:
 				typ = &ast.Ident{Name: resolvedName}
 			}
 		}
-		if err := fillEnumVariations(&p, ref.File, pkg, typ.Name); err != nil {
+		if err := prog.Loader.fillEnumVariations(ctx, &p, ref.File, pkg, typ.Name); err != nil {
 			return nil, err
 		}
 		if mappedType == "" {
 			// Only check for canonicalType if this isn't mapped.
-			canon, err := canonicalType(ref.File, pkg, typ)
+			canon, err := prog.Loader.canonicalType(ctx, ref.File, pkg, typ)
 			if err != nil {
 				return nil, fmt.Errorf("cannot get canonical type: %v", err)
 			}
@@ -402,7 +531,7 @@ start:
 		p.Properties = map[string]*Schema{}
 		for _, f := range typ.Fields.List {
 			propName := goutil.TagName(f, tagName)
-			prop, err := fieldToSchema(prog, propName, tagName, ref, f, generics)
+			prop, err := fieldToSchema(ctx, prog, propName, tagName, ref, f, generics)
 			if err != nil {
 				return nil, fmt.Errorf("anon struct: %v", err)
 			}
@@ -426,7 +555,7 @@ start:
 		}
 
 		// Only check for canonicalType if this isn't mapped.
-		canon, err := canonicalType(ref.File, pkgSel.Name, typ.Sel)
+		canon, err := prog.Loader.canonicalType(ctx, ref.File, pkgSel.Name, typ.Sel)
 		if err != nil {
 			return nil, fmt.Errorf("cannot get canonical type: %v", err)
 		}
@@ -434,7 +563,7 @@ start:
 			// Resolve enum variations before goto start: after re-entry the
 			// type name becomes the canonical primitive (e.g. "string") and
 			// getEnumVariations would look for the wrong type.
-			if err := fillEnumVariations(&p, ref.File, pkg, name.Name); err != nil {
+			if err := prog.Loader.fillEnumVariations(ctx, &p, ref.File, pkg, name.Name); err != nil {
 				return nil, err
 			}
 			sw = canon
@@ -444,7 +573,7 @@ start:
 		// Deal with array.
 		// TODO: don't do this inline but at the end. Reason it doesn't work not
 		// is because we always use GetReference().
-		ts, _, importPath, err := findType(ref.File, pkg, name.Name)
+		ts, _, importPath, err := prog.Loader.findType(ctx, ref.File, pkg, name.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -462,7 +591,7 @@ start:
 		if resolvType, ok := ts.Type.(*ast.ArrayType); ok {
 			isEnum := p.Type == "enum"
 			p.Type = "array"
-			if err = resolveArray(prog, ref, pkg, &p, resolvType.Elt, isEnum, generics); err != nil {
+			if err = resolveArray(ctx, prog, ref, pkg, &p, resolvType.Elt, isEnum, generics); err != nil {
 				return nil, err
 			}
 
@@ -471,7 +600,7 @@ start:
 
 	// Maps
 	case *ast.MapType:
-		if err := resolveMap(prog, ref, pkg, &p, typ, generics); err != nil {
+		if err := resolveMap(ctx, prog, ref, pkg, &p, typ, generics); err != nil {
 			return nil, err
 		}
 		return &p, nil
@@ -481,7 +610,7 @@ start:
 		isEnum := p.Type == "enum"
 		p.Type = "array"
 
-		err := resolveArray(prog, ref, pkg, &p, typ.Elt, isEnum, generics)
+		err := resolveArray(ctx, prog, ref, pkg, &p, typ.Elt, isEnum, generics)
 		if err != nil {
 			return nil, err
 		}
@@ -499,7 +628,7 @@ start:
 		} else if mapped {
 			return &p, nil
 		}
-		if err := fillGenericsSchema(prog, &p, tagName, ref, genericsPkg, genericsIdent, generics, typ.Index); err != nil {
+		if err := fillGenericsSchema(ctx, prog, &p, tagName, ref, genericsPkg, genericsIdent, generics, typ.Index); err != nil {
 			return nil, fmt.Errorf("generic fieldToSchema: %v", err)
 		}
 		return &p, nil
@@ -514,7 +643,7 @@ start:
 		} else if mapped {
 			return &p, nil
 		}
-		err = fillGenericsSchema(prog, &p, tagName, ref, genericsPkg, genericsIdent, generics, typ.Indices...)
+		err = fillGenericsSchema(ctx, prog, &p, tagName, ref, genericsPkg, genericsIdent, generics, typ.Indices...)
 		if err != nil {
 			return nil, fmt.Errorf("generic fieldToSchema: %v", err)
 		}
@@ -530,7 +659,7 @@ start:
 
 	// Check if the type resolves to a Go primitive.
 	lookup := pkg + "." + name.Name
-	t, err := getTypeInfo(prog, lookup, ref.File)
+	t, err := getTypeInfo(ctx, prog, lookup, ref.File)
 	if err != nil {
 		return nil, err
 	}
@@ -611,6 +740,7 @@ func genericMapTypeLookup(
 // types can be different for every generics declaration they will need to be a
 // anonymous object in the schema output instead of a reusable reference.
 func fillGenericsSchema(
+	ctx context.Context,
 	prog *Program,
 	p *Schema,
 	tagName string,
@@ -620,7 +750,7 @@ func fillGenericsSchema(
 	generics map[string]string,
 	indices ...ast.Expr,
 ) error {
-	genericsType, genericsFilePath, resolvedPkg, err := findType(ref.File, genericsPkg, genericsIdent.Name)
+	genericsType, genericsFilePath, resolvedPkg, err := prog.Loader.findType(ctx, ref.File, genericsPkg, genericsIdent.Name)
 	if err != nil {
 		return fmt.Errorf("cannot find generic type: %v", err)
 	}
@@ -653,7 +783,7 @@ func fillGenericsSchema(
 				// Cross-package type argument: resolve the import alias to the
 				// full package path so it can be looked up unambiguously later,
 				// regardless of which file provides the resolution context.
-				resolvedArgPkg, _, resolveErr := resolvePackage(ref.File, argPkg)
+				resolvedArgPkg, _, resolveErr := prog.Loader.resolvePackage(ctx, ref.File, argPkg)
 				if resolveErr != nil {
 					return fmt.Errorf("cannot resolve package %q for generic type argument: %v", argPkg, resolveErr)
 				}
@@ -676,7 +806,7 @@ func fillGenericsSchema(
 
 	for _, field := range genericsStruct.Fields.List {
 		fieldName := goutil.TagName(field, tagName)
-		schema, err := fieldToSchema(prog, fieldName, tagName, genericRef, field, generics)
+		schema, err := fieldToSchema(ctx, prog, fieldName, tagName, genericRef, field, generics)
 		if err != nil {
 			return fmt.Errorf("generic fieldToSchema: %v", err)
 		}
@@ -688,11 +818,11 @@ func fillGenericsSchema(
 
 // fillEnumVariations populates p.Enum if p.Type is "enum" and no values have
 // been set yet. It is a no-op otherwise.
-func fillEnumVariations(p *Schema, currentFile, pkgPath, typeName string) error {
+func (l *Loader) fillEnumVariations(ctx context.Context, p *Schema, currentFile, pkgPath, typeName string) error {
 	if p.Type != "enum" || len(p.Enum) != 0 {
 		return nil
 	}
-	variations, err := getEnumVariations(currentFile, pkgPath, typeName)
+	variations, err := l.getEnumVariations(ctx, currentFile, pkgPath, typeName)
 	if err != nil {
 		return err
 	}
@@ -701,12 +831,12 @@ func fillEnumVariations(p *Schema, currentFile, pkgPath, typeName string) error
 }
 
 // Helper function to extract enum variations from a file.
-func getEnumVariations(currentFile, pkgPath, typeName string) ([]string, error) {
-	resolvedPath, pkg, err := resolvePackage(currentFile, pkgPath)
+func (l *Loader) getEnumVariations(ctx context.Context, currentFile, pkgPath, typeName string) ([]string, error) {
+	resolvedPath, pkg, err := l.resolvePackage(ctx, currentFile, pkgPath)
 	if err != nil {
 		return nil, fmt.Errorf("could not resolve package: %v", err)
 	}
-	decls, err := getDecls(pkg, resolvedPath)
+	decls, err := l.getDecls(pkg, resolvedPath)
 	if err != nil {
 		return nil, err
 	}
@@ -760,10 +890,10 @@ func findTypeIdent(typ ast.Expr, curPkg string) (*ast.Ident, string, error) {
 	return se.Sel, pkgSel.Name, nil
 }
 
-func lookupTypeAndRef(file, pkg, name string) (string, string, error) {
+func (l *Loader) lookupTypeAndRef(ctx context.Context, file, pkg, name string) (string, string, error) {
 	// Check if the type resolves to a Go primitive.
 	lookup := pkg + "." + name
-	ts, _, _, err := findType(file, pkg, name)
+	ts, _, _, err := l.findType(ctx, file, pkg, name)
 	if err != nil {
 		return "", "", err
 	}
@@ -782,6 +912,7 @@ func lookupTypeAndRef(file, pkg, name string) (string, string, error) {
 // information). Slice value types are handled explicitly so that e.g.
 // `map[K][]T` doesn't lose its element type.
 func resolveMap(
+	ctx context.Context,
 	prog *Program,
 	ref Reference,
 	pkg string,
@@ -794,14 +925,14 @@ func resolveMap(
 	switch v := dropTypePointers(typ.Value).(type) {
 	case *ast.ArrayType:
 		items := &Schema{Type: "array"}
-		if err := resolveArray(prog, ref, pkg, items, v.Elt, false, generics); err != nil {
+		if err := resolveArray(ctx, prog, ref, pkg, items, v.Elt, false, generics); err != nil {
 			return fmt.Errorf("resolveMap resolveArray: %v", err)
 		}
 		p.AdditionalProperties = items
 		return nil
 	case *ast.MapType:
 		inner := &Schema{}
-		if err := resolveMap(prog, ref, pkg, inner, v, generics); err != nil {
+		if err := resolveMap(ctx, prog, ref, pkg, inner, v, generics); err != nil {
 			return fmt.Errorf("resolveMap nested: %v", err)
 		}
 		p.AdditionalProperties = inner
@@ -810,7 +941,7 @@ func resolveMap(
 
 	vtyp, vpkg, err := findTypeIdent(typ.Value, pkg)
 	if err != nil {
-		dbg("ERR FOUND MapType: %s", err.Error())
+		prog.Loader.dbg("ERR FOUND MapType: %s", err.Error())
 		return nil
 	}
 	if generics != nil && generics[vtyp.Name] != "" {
@@ -823,19 +954,20 @@ func resolveMap(
 		return nil
 	}
 
-	_, lref, err := lookupTypeAndRef(ref.File, vpkg, vtyp.Name)
+	_, lref, err := prog.Loader.lookupTypeAndRef(ctx, ref.File, vpkg, vtyp.Name)
 	if err != nil {
-		dbg("ERR, Could not find additionalProperties: %s", err.Error())
+		prog.Loader.dbg("ERR, Could not find additionalProperties: %s", err.Error())
 		return nil
 	}
 	p.AdditionalProperties = &Schema{Reference: lref}
-	if _, err := GetReference(prog, ref.Context, false, lref, ref.File); err != nil {
-		dbg("ERR, Could not find additionalProperties Reference: %s", err.Error())
+	if _, err := getReference(ctx, prog, ref.Context, false, lref, ref.File); err != nil {
+		prog.Loader.dbg("ERR, Could not find additionalProperties Reference: %s", err.Error())
 	}
 	return nil
 }
 
 func resolveArray(
+	ctx context.Context,
 	prog *Program,
 	ref Reference,
 	pkg string,
@@ -862,7 +994,7 @@ arrayStart:
 	// Simple identifier: "string", "myCustomType".
 	case *ast.Ident:
 
-		dbg("resolveArray: ident: %#v in %#v", typ.Name, pkg)
+		prog.Loader.dbg("resolveArray: ident: %#v in %#v", typ.Name, pkg)
 
 		if generics != nil && generics[typ.Name] != "" {
 			p.Items = &Schema{Type: JSONSchemaType(generics[typ.Name])}
@@ -899,7 +1031,7 @@ arrayStart:
 	// "pkg.foo"
 	case *ast.SelectorExpr:
 
-		dbg("resolveArray: selector: %#v -> %#v", typ.X, typ.Sel)
+		prog.Loader.dbg("resolveArray: selector: %#v -> %#v", typ.X, typ.Sel)
 
 		pkgSel, ok := typ.X.(*ast.Ident)
 		if !ok {
@@ -909,7 +1041,7 @@ arrayStart:
 		name = typ.Sel
 
 		// handle import aliases
-		_, _, resolved, err := findType(ref.File, pkg, name.Name)
+		_, _, resolved, err := prog.Loader.findType(ctx, ref.File, pkg, name.Name)
 		if err != nil {
 			return fmt.Errorf("resolveArray: findType: %v", err)
 		}
@@ -946,7 +1078,7 @@ arrayStart:
 	}
 
 	// Check if the type resolves to a Go primitive.
-	t, err := getTypeInfo(prog, lookup, ref.File)
+	t, err := getTypeInfo(ctx, prog, lookup, ref.File)
 	if err != nil {
 		return err
 	}
@@ -956,7 +1088,7 @@ arrayStart:
 		}
 		p.Items.Type = t
 		if isEnum && len(p.Items.Enum) == 0 {
-			if variations, err := getEnumVariations(ref.File, pkg, name.Name); len(variations) > 0 {
+			if variations, err := prog.Loader.getEnumVariations(ctx, ref.File, pkg, name.Name); len(variations) > 0 {
 				p.Items.Enum = variations
 			} else if err != nil {
 				return err
@@ -975,7 +1107,7 @@ arrayStart:
 	rName, rPkg := ParseLookup(lookup, ref.File)
 
 	if _, ok := prog.References[filepath.Base(rPkg)+"."+rName]; !ok {
-		_, err = GetReference(prog, ref.Context, false, lookup, ref.File)
+		_, err = getReference(ctx, prog, ref.Context, false, lookup, ref.File)
 	}
 	return err
 }
@@ -1015,14 +1147,12 @@ func JSONSchemaType(t string) string {
 	return t
 }
 
-func getTypeInfo(_ *Program, lookup, filePath string) (string, error) {
-	// TODO: REMOVE THE prog PARAM, as this function is not
-	// using it anymore.
-	dbg("getTypeInfo: %#v in %#v", lookup, filePath)
+func getTypeInfo(ctx context.Context, prog *Program, lookup, filePath string) (string, error) {
+	prog.Loader.dbg("getTypeInfo: %#v in %#v", lookup, filePath)
 	name, pkg := ParseLookup(lookup, filePath)
 
 	// Find type.
-	ts, _, _, err := findType(filePath, pkg, name)
+	ts, _, _, err := prog.Loader.findType(ctx, filePath, pkg, name)
 	if err != nil {
 		return "", err
 	}
@@ -1039,7 +1169,7 @@ func getTypeInfo(_ *Program, lookup, filePath string) (string, error) {
 }
 
 // Get the canonical type.
-func canonicalType(currentFile, pkgPath string, typ *ast.Ident) (ast.Expr, error) {
+func (l *Loader) canonicalType(ctx context.Context, currentFile, pkgPath string, typ *ast.Ident) (ast.Expr, error) {
 	if goutil.PredeclaredType(typ.Name) {
 		return nil, nil
 	}
@@ -1047,7 +1177,7 @@ func canonicalType(currentFile, pkgPath string, typ *ast.Ident) (ast.Expr, error
 	var ts *ast.TypeSpec
 	if typ.Obj == nil {
 		var err error
-		ts, _, _, err = findType(currentFile, pkgPath, typ.Name)
+		ts, _, _, err = l.findType(ctx, currentFile, pkgPath, typ.Name)
 		if err != nil {
 			return nil, err
 		}
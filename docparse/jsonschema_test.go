@@ -1,6 +1,7 @@
 package docparse
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/build"
@@ -19,15 +20,15 @@ func TestFieldToProperty(t *testing.T) {
 		"b":         {Type: "boolean", Description: "Inline docs."},
 		"fl":        {Type: "number"},
 		"err":       {Type: "string"},
-		"strP":      {Type: "string"},
+		"strP":      {Type: "string", Nullable: true},
 		"slice":     {Type: "array", Items: &Schema{Type: "string"}},
 		"sliceP":    {Type: "array", Items: &Schema{Type: "string"}},
 		"cstr":      {Type: "string"},
-		"cstrP":     {Type: "string"},
+		"cstrP":     {Type: "string", Nullable: true},
 		"enumStr":   {Type: "string", Enum: []string{"a", "b", "c"}},
 		"enumsStr":  {Type: "array", Items: &Schema{Type: "string", Enum: []string{"a", "b", "c"}}},
 		"bar":       {Reference: "a.bar"},
-		"barP":      {Reference: "a.bar"},
+		"barP":      {Reference: "a.bar", Nullable: true},
 		"pkg":       {Reference: "mail.Address"},
 		"pkgSlice":  {Type: "array", Items: &Schema{Reference: "mail.Address"}},
 		"pkgSliceP": {Type: "array", Items: &Schema{Reference: "mail.Address"}},
@@ -39,8 +40,10 @@ func TestFieldToProperty(t *testing.T) {
 		},
 	}
 
+	gopath := build.Default.GOPATH
+	t.Cleanup(func() { build.Default.GOPATH = gopath })
 	build.Default.GOPATH = "./testdata"
-	ts, _, _, err := findType("./testdata/src/a/a.go", "a", "foo")
+	ts, _, _, err := NewLoader().findType(context.Background(), "./testdata/src/a/a.go", "a", "foo")
 	if err != nil {
 		t.Fatalf("could not parse file: %v", err)
 	}
@@ -53,7 +56,7 @@ func TestFieldToProperty(t *testing.T) {
 	for i, f := range st.Fields.List {
 		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
 			prog := NewProgram(false)
-			out, err := fieldToSchema(prog, f.Names[0].Name, "json", Reference{
+			out, err := fieldToSchema(context.Background(), prog, f.Names[0].Name, "json", Reference{
 				Package: "a",
 				File:    "./testdata/src/a/a.go",
 				Context: "req",
@@ -113,7 +116,7 @@ func TestFieldToProperty(t *testing.T) {
 
 		for _, tc := range cases {
 			t.Run(tc.name, func(t *testing.T) {
-				ts, _, _, err := findType("./testdata/src/a/a.go", "a", "mapped")
+				ts, _, _, err := NewLoader().findType(context.Background(), "./testdata/src/a/a.go", "a", "mapped")
 				if err != nil {
 					t.Fatalf("could not parse file: %v", err)
 				}
@@ -127,7 +130,7 @@ func TestFieldToProperty(t *testing.T) {
 
 				for _, f := range st.Fields.List {
 					name := f.Names[0].Name
-					out, err := fieldToSchema(prog, name, "json", Reference{
+					out, err := fieldToSchema(context.Background(), prog, name, "json", Reference{
 						Package: "a",
 						File:    "./testdata/src/a/a.go",
 						Context: "req",
@@ -154,7 +157,7 @@ func TestFieldToProperty(t *testing.T) {
 		}
 
 		prog := NewProgram(false)
-		ts, _, _, err := findType("./testdata/src/a/a.go", "a", "withExternalEnum")
+		ts, _, _, err := prog.Loader.findType(context.Background(), "./testdata/src/a/a.go", "a", "withExternalEnum")
 		if err != nil {
 			t.Fatalf("could not parse file: %v", err)
 		}
@@ -165,7 +168,7 @@ func TestFieldToProperty(t *testing.T) {
 		}
 
 		for _, f := range st.Fields.List {
-			out, err := fieldToSchema(prog, f.Names[0].Name, "json", Reference{
+			out, err := fieldToSchema(context.Background(), prog, f.Names[0].Name, "json", Reference{
 				Package: "a",
 				File:    "./testdata/src/a/a.go",
 				Context: "req",
@@ -188,7 +191,7 @@ func TestFieldToProperty(t *testing.T) {
 
 	t.Run("nested", func(t *testing.T) {
 		prog := NewProgram(false)
-		ts, _, _, err := findType("./testdata/src/a/a.go", "a", "nested")
+		ts, _, _, err := prog.Loader.findType(context.Background(), "./testdata/src/a/a.go", "a", "nested")
 		if err != nil {
 			t.Fatalf("could not parse file: %v", err)
 		}
@@ -199,7 +202,7 @@ func TestFieldToProperty(t *testing.T) {
 		}
 
 		for _, f := range st.Fields.List {
-			out, err := fieldToSchema(prog, f.Names[0].Name, "json", Reference{
+			out, err := fieldToSchema(context.Background(), prog, f.Names[0].Name, "json", Reference{
 				Package: "a",
 				File:    "./testdata/src/a/a.go",
 			}, f, nil)
@@ -246,3 +249,28 @@ func TestIsInferredRequired(t *testing.T) {
 		})
 	}
 }
+
+func TestIsDeprecated(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"", false},
+		{"The name.", false},
+		{"Deprecated: use Name.", true},
+		{"Deprecated:", true},
+		{"The name.\n\nDeprecated: use Name.", true},
+		{"The name.\n\nDeprecated:", true},
+		{"The name.\n\n  Deprecated:  ", true},
+		{"Not Deprecated: at all.", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got := isDeprecated(tc.in)
+			if got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
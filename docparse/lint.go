@@ -0,0 +1,231 @@
+package docparse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/teamwork/utils/v2/goutil"
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+// LintRule checks the program for a type of problem.
+type LintRule struct {
+	Code    string // Identifier used in diagnostics and the configuration.
+	Desc    string // Short description.
+	Default bool   // Enabled by default.
+
+	Check func(prog *Program, report func(pos token.Position, msg string))
+}
+
+// LintRules are all the rules Lint can check.
+var LintRules = []LintRule{
+	{"no-tagline", "endpoint has no tagline", true, lintTagline},
+	{"field-doc", "request or response field has no documentation", false, lintFieldDoc},
+	{"path-doc", "path parameter has no documentation", true, lintPathDoc},
+	{"empty-enum", "{enum} has no values", true, lintEnum},
+	{"missing-4xx", "common 4xx response is not documented", true, lint4xx},
+}
+
+// Lint parses the program and checks it for problems with all rules enabled
+// in the configuration (Config.LintEnable and Config.LintDisable).
+//
+// Errors in comments are reported as a "parse-error" diagnostic, rather than
+// returned as an error.
+func Lint(prog *Program) (Diagnostics, error) {
+	rules, err := lintRules(prog.Config.LintEnable, prog.Config.LintDisable)
+	if err != nil {
+		return nil, err
+	}
+
+	var diags Diagnostics
+	if err := Parse(prog); err != nil && !errors.As(err, &diags) {
+		return nil, err
+	}
+
+	for _, r := range rules {
+		r.Check(prog, func(pos token.Position, msg string) {
+			diags = append(diags, Diagnostic{Pos: pos, Severity: SeverityWarning, Code: r.Code, Message: msg})
+		})
+	}
+
+	diags.Sort()
+	return diags, nil
+}
+
+// lintRules gets the rules to check; enable and disable are lists of codes.
+func lintRules(enable, disable []string) ([]LintRule, error) {
+	on := make(map[string]bool)
+	for _, r := range LintRules {
+		on[r.Code] = r.Default
+	}
+	for _, list := range []struct {
+		codes []string
+		v     bool
+	}{{enable, true}, {disable, false}} {
+		for _, c := range list.codes {
+			if _, ok := on[c]; !ok {
+				return nil, fmt.Errorf("unknown lint rule %q", c)
+			}
+			on[c] = list.v
+		}
+	}
+
+	var rules []LintRule
+	for _, r := range LintRules {
+		if on[r.Code] {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func lintTagline(prog *Program, report func(token.Position, string)) {
+	for _, e := range prog.Endpoints {
+		if e.Tagline == "" {
+			report(e.Pos, fmt.Sprintf("%s %s has no tagline", e.Method, e.Path))
+		}
+	}
+}
+
+func lintFieldDoc(prog *Program, report func(token.Position, string)) {
+	lintFields(prog, func(ref Reference, f *ast.Field, name string, pos token.Position) {
+		if ref.Context == ctxPath {
+			return
+		}
+		if doc, _ := fieldDoc(f); doc == "" {
+			report(pos, fmt.Sprintf("field %s.%s has no documentation", ref.Lookup, name))
+		}
+	})
+}
+
+func lintPathDoc(prog *Program, report func(token.Position, string)) {
+	lintFields(prog, func(ref Reference, f *ast.Field, name string, pos token.Position) {
+		if doc, _ := fieldDoc(f); ref.Context == ctxPath && doc == "" {
+			report(pos, fmt.Sprintf("path parameter %s.%s has no documentation", ref.Lookup, name))
+		}
+	})
+}
+
+func lintEnum(prog *Program, report func(token.Position, string)) {
+	lintFields(prog, func(ref Reference, f *ast.Field, name string, pos token.Position) {
+		if ref.Schema == nil {
+			return
+		}
+		if _, tags := fieldDoc(f); !sliceutil.Contains(tags, paramEnum) {
+			return
+		}
+
+		p, ok := ref.Schema.Properties[name]
+		if !ok {
+			return
+		}
+		if p.Type == "array" && p.Items != nil {
+			p = p.Items
+		}
+		if len(p.Enum) == 0 {
+			report(pos, fmt.Sprintf("field %s.%s has {enum} without values", ref.Lookup, name))
+		}
+	})
+}
+
+func lint4xx(prog *Program, report func(token.Position, string)) {
+	for _, e := range prog.Endpoints {
+		need := make(map[int]string)
+		if e.Request.Body != nil || e.Request.Form != nil || e.Request.Query != nil {
+			need[400] = "accepts parameters"
+		}
+		if strings.Contains(e.Path, "{") {
+			need[404] = "has path parameters"
+		}
+		if e.Security == nil && len(prog.Config.DefaultSecurity) > 0 || len(e.Security) > 0 {
+			need[401] = "requires authentication"
+		}
+
+		codes := make([]int, 0, len(need))
+		for c := range need {
+			if _, ok := e.Responses[c]; !ok {
+				codes = append(codes, c)
+			}
+		}
+		sort.Ints(codes)
+		for _, c := range codes {
+			report(e.Pos, fmt.Sprintf("%s %s %s but has no %d response", e.Method, e.Path, need[c], c))
+		}
+	}
+}
+
+// lintFields calls fn for every exported field in all references.
+func lintFields(prog *Program, fn func(ref Reference, f *ast.Field, name string, pos token.Position)) {
+	lookups := make([]string, 0, len(prog.References))
+	for k := range prog.References {
+		lookups = append(lookups, k)
+	}
+	sort.Strings(lookups)
+
+	for _, l := range lookups {
+		ref := prog.References[l]
+		// The references are loaded by Parse already, so this doesn't load
+		// any packages.
+		ts, file, _, err := prog.Loader.findType(context.Background(), ref.File, ref.Package, ref.Name)
+		if err != nil {
+			continue
+		}
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok {
+			continue
+		}
+
+		tagName := prog.Config.StructTag
+		switch ref.Context {
+		case ctxPath, ctxQuery, ctxForm, ctxHeader, ctxCookie:
+			tagName = ref.Context
+		}
+
+		for _, f := range st.Fields.List {
+			if len(f.Names) == 0 || (f.Comment != nil && hasTag(f.Comment.Text(), paramOmitDoc)) {
+				continue
+			}
+			name := goutil.TagName(f, tagName)
+			if name == "-" {
+				continue
+			}
+			for _, n := range f.Names {
+				if !n.IsExported() {
+					continue
+				}
+				fName := name
+				if fName == "" || len(f.Names) > 1 {
+					fName = n.Name
+				}
+				fn(ref, f, fName, prog.Loader.filePosition(file, n.Pos()))
+			}
+		}
+	}
+}
+
+// fieldDoc gets the documentation of f and the {..} parameter properties in
+// it.
+func fieldDoc(f *ast.Field) (string, []string) {
+	var doc string
+	if f.Doc != nil {
+		doc = f.Doc.Text()
+	} else if f.Comment != nil {
+		doc = f.Comment.Text()
+	}
+	doc, tags := parseTags(strings.TrimSpace(doc))
+	return strings.TrimSpace(doc), tags
+}
+
+// filePosition converts pos in the cached AST for file to a token.Position.
+func (l *Loader) filePosition(file string, pos token.Pos) token.Position {
+	f, ok := l.files.Load(file)
+	if !ok {
+		return token.Position{Filename: file}
+	}
+	return f.(cachedFile).tok.Position(pos)
+}
@@ -0,0 +1,56 @@
+package docparse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/teamwork/test"
+	"github.com/teamwork/test/diff"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		enable  []string
+		disable []string
+		want    []string
+		wantErr string
+	}{
+		{"default", nil, nil, []string{
+			"testdata/src/lint/lint.go:7:2: path-doc: path parameter lint.pathParams.version has no documentation",
+			"testdata/src/lint/lint.go:21:2: empty-enum: field lint.object.kind has {enum} without values",
+			"testdata/src/lint/lint.go:33:1: no-tagline: POST /objects has no tagline",
+			"testdata/src/lint/lint.go:33:1: missing-4xx: POST /objects accepts parameters but has no 400 response",
+			`testdata/src/lint/lint.go:41:1: parse-error: parameter "version" is not in the path "/objects/{id}"`,
+		}, ""},
+		{"enable-disable", []string{"field-doc"}, []string{"missing-4xx", "no-tagline", "empty-enum"}, []string{
+			"testdata/src/lint/lint.go:7:2: path-doc: path parameter lint.pathParams.version has no documentation",
+			"testdata/src/lint/lint.go:20:2: field-doc: field lint.object.name has no documentation",
+			`testdata/src/lint/lint.go:41:1: parse-error: parameter "version" is not in the path "/objects/{id}"`,
+		}, ""},
+		{"unknown", []string{"nope"}, nil, nil, `unknown lint rule "nope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := NewProgram(false)
+			prog.Config.Packages = []string{"./testdata/src/lint"}
+			prog.Config.StructTag = "json"
+			prog.Config.LintEnable = tt.enable
+			prog.Config.LintDisable = tt.disable
+
+			diags, err := Lint(prog)
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+
+			var out []string
+			for _, d := range diags {
+				out = append(out, d.String())
+			}
+			if !reflect.DeepEqual(tt.want, out) {
+				t.Errorf("\n%v", diff.Diff(tt.want, out))
+			}
+		})
+	}
+}
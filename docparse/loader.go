@@ -0,0 +1,82 @@
+package docparse
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Loader finds and parses Go packages, and caches the results.
+//
+// A Loader is safe for concurrent use, and can be shared between Programs to
+// share the caches. Changes to files aren't picked up until they're removed
+// with Invalidate, so create a new Loader (e.g. with NewProgram) to always
+// read the current files.
+type Loader struct {
+	Debug bool // Print debug output to stderr.
+
+	mu    sync.Mutex
+	decls map[string]*declsEntry // Declarations by package path; guarded by mu.
+
+	files   sync.Map // Parsed files by path; value is cachedFile.
+	imports sync.Map // Imports by file path; value is map of name to import path.
+	pkgs    sync.Map // Resolved packages by import path; value is pkgResult.
+	schemas sync.Map // Files read for "{schema: ..}", "Extend:", and examples; value is struct{}.
+	broken  sync.Map // Go files which couldn't be parsed; value is struct{}.
+
+	disk atomic.Pointer[diskCache] // On-disk cache; nil if it's disabled. Set by SetCacheDir.
+
+	cwdOnce sync.Once
+	cwd     string // Working directory to resolve packages from; set by cwdOnce.
+	cwdErr  error
+}
+
+// cachedFile is a parsed file in Loader.files.
+type cachedFile struct {
+	ast *ast.File
+	tok *token.File // Every file has its own FileSet.
+}
+
+type declsEntry struct {
+	dir   string
+	once  sync.Once
+	decls []declCache
+	err   error
+}
+
+// NewLoader creates a new Loader with empty caches.
+func NewLoader() *Loader {
+	return &Loader{decls: make(map[string]*declsEntry)}
+}
+
+// SetCacheDir enables the on-disk cache of package lists and declarations of
+// referenced packages in dir, or disables it if dir is empty.
+//
+// This applies to everything loaded with the Loader, including for other
+// Programs sharing it; call it before the Loader is used.
+func (l *Loader) SetCacheDir(dir string) {
+	if dir == "" {
+		l.disk.Store(nil)
+		return
+	}
+	l.disk.Store(&diskCache{dir: dir, l: l})
+}
+
+func (l *Loader) dbg(s string, a ...interface{}) {
+	if l != nil && l.Debug {
+		_, _ = fmt.Fprintf(os.Stderr, "\x1b[38;5;244mdbg docparse: "+s+"\x1b[0m\n", a...)
+	}
+}
+
+// readSchemaFile reads a JSON or YAML file, and records it in the list of
+// WatchFiles.
+func (l *Loader) readSchemaFile(path string, target interface{}) error {
+	if abs, err := filepath.Abs(path); err == nil {
+		l.schemas.Store(abs, struct{}{})
+	}
+	return readAndUnmarshalSchemaFile(path, target)
+}
@@ -0,0 +1,85 @@
+package docparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/teamwork/test/diff"
+)
+
+// Run with -race to check for data races.
+func TestConcurrent(t *testing.T) {
+	output := func(w io.Writer, prog *Program) error {
+		for _, e := range prog.Endpoints {
+			fmt.Fprintln(w, e.Method, e.Path)
+		}
+		schemas := make(map[string]*Schema)
+		for k, r := range prog.References {
+			schemas[k] = r.Schema
+		}
+		return json.NewEncoder(w).Encode(schemas)
+	}
+	run := func(l *Loader) (string, error) {
+		prog := NewProgram(false)
+		if l != nil {
+			prog.Loader = l
+		}
+		// The alias package refers to imported packages by their name.
+		prog.Config.Packages = []string{"../example/...", "./testdata/src/alias"}
+		prog.Config.StructTag = "json"
+		prog.Config.Output = output
+
+		buf := new(bytes.Buffer)
+		err := FindComments(buf, prog)
+		return buf.String(), err
+	}
+
+	// The output is compared to a sequential run afterwards, so the concurrent
+	// runs start with empty caches.
+	var want string
+	for _, tt := range []struct {
+		name   string
+		loader func() *Loader
+	}{
+		{"separate", func() *Loader { return nil }},
+		{"shared", func() func() *Loader {
+			l := NewLoader()
+			return func() *Loader { return l }
+		}()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			outs := make([]string, 8)
+			for i := range outs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					out, err := run(tt.loader())
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					outs[i] = out
+				}()
+			}
+			wg.Wait()
+
+			if want == "" {
+				var err error
+				want, err = run(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, out := range outs {
+				if d := diff.TextDiff(want, out); d != "" {
+					t.Error(d)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package docparse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// OutputFormat is an output function registered with RegisterOutput.
+type OutputFormat struct {
+	Name  string
+	Desc  string
+	Write func(io.Writer, *Program) error
+}
+
+var (
+	outputsMu sync.RWMutex
+	outputs   []OutputFormat
+)
+
+// RegisterOutput makes the output function fn available as name, for the
+// -output flag and the "output" key in the configuration file. Names are
+// case-insensitive.
+//
+// This is usually called from init(); it panics if name is already
+// registered.
+func RegisterOutput(name, description string, fn func(io.Writer, *Program) error) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+
+	for _, o := range outputs {
+		if strings.EqualFold(o.Name, name) {
+			panic(fmt.Sprintf("docparse.RegisterOutput: output %q is already registered", name))
+		}
+	}
+	outputs = append(outputs, OutputFormat{Name: name, Desc: description, Write: fn})
+}
+
+// LookupOutput gets the output function registered as name.
+func LookupOutput(name string) (func(io.Writer, *Program) error, bool) {
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+
+	for _, o := range outputs {
+		if strings.EqualFold(o.Name, name) {
+			return o.Write, true
+		}
+	}
+	return nil, false
+}
+
+// Outputs gets all registered output functions, in the order they were
+// registered.
+func Outputs() []OutputFormat {
+	outputsMu.RLock()
+	defer outputsMu.RUnlock()
+	return append([]OutputFormat(nil), outputs...)
+}
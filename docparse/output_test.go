@@ -0,0 +1,50 @@
+package docparse
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// unregisterOutput removes the output registered as name.
+func unregisterOutput(name string) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+
+	for i, o := range outputs {
+		if strings.EqualFold(o.Name, name) {
+			outputs = append(outputs[:i], outputs[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestRegisterOutput(t *testing.T) {
+	fn := func(io.Writer, *Program) error { return nil }
+	RegisterOutput("test-output", "Test", fn)
+	t.Cleanup(func() { unregisterOutput("test-output") })
+
+	if _, ok := LookupOutput("TEST-output"); !ok {
+		t.Error("not found")
+	}
+	if _, ok := LookupOutput("test"); ok {
+		t.Error("found prefix")
+	}
+
+	found := false
+	for _, o := range Outputs() {
+		if o.Name == "test-output" && o.Desc == "Test" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("not in Outputs()")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for duplicate name")
+		}
+	}()
+	RegisterOutput("Test-Output", "Duplicate", fn)
+}
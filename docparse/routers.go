@@ -0,0 +1,490 @@
+package docparse
+
+import (
+	"go/ast"
+	"strings"
+
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+// methodCall gets the receiver and method name if c is a method call (or a
+// function call on a package).
+func methodCall(c *ast.CallExpr) (recv ast.Expr, name string, ok bool) {
+	sel, ok := c.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, "", false
+	}
+	return sel.X, sel.Sel.Name, true
+}
+
+// callChain gets the calls in a method chain such as r.Path("/").Methods("GET"),
+// in order, and the receiver of the first call.
+func callChain(c *ast.CallExpr) (recv ast.Expr, calls []*ast.CallExpr) {
+	for {
+		calls = append([]*ast.CallExpr{c}, calls...)
+		x, _, ok := methodCall(c)
+		if !ok {
+			return nil, calls
+		}
+		inner, ok := x.(*ast.CallExpr)
+		if !ok {
+			return x, calls
+		}
+		c = inner
+	}
+}
+
+// funcLitParam gets the first parameter of the function literal e.
+func funcLitParam(e ast.Expr) *ast.Ident {
+	fn, ok := e.(*ast.FuncLit)
+	if !ok || len(fn.Type.Params.List) == 0 || len(fn.Type.Params.List[0].Names) == 0 {
+		return nil
+	}
+	return fn.Type.Params.List[0].Names[0]
+}
+
+// colonPath converts the :name and *name wildcards used by echo and gin to
+// {name} and {name...}.
+func colonPath(path string) string {
+	s := strings.Split(path, "/")
+	for i := range s {
+		switch {
+		case strings.HasPrefix(s[i], ":"):
+			s[i] = "{" + s[i][1:] + "}"
+		case strings.HasPrefix(s[i], "*"):
+			name := s[i][1:]
+			if name == "" {
+				name = "path"
+			}
+			s[i] = "{" + name + "...}"
+		}
+	}
+	return strings.Join(s, "/")
+}
+
+// serveMuxRoutes finds routes registered with net/http.ServeMux: calls to
+// http.Handle, http.HandleFunc, and the Handle and HandleFunc methods with a
+// "[METHOD ][HOST]/[PATH]" pattern.
+//
+// Routers mounted with mux.Handle("/api/", http.StripPrefix("/api", sub)) get
+// the prefix.
+type serveMuxRoutes struct{}
+
+func (serveMuxRoutes) Imports(path string) bool { return path == "net/http" }
+
+// RouterType reports if name is a router type in net/http.
+func (serveMuxRoutes) RouterType(name string) bool { return name == "ServeMux" }
+
+func (serveMuxRoutes) Router(c *ast.CallExpr, s *RouteScope) (string, bool) {
+	recv, name, ok := methodCall(c)
+	return "", ok && s.Package(recv) == "net/http" && name == "NewServeMux"
+}
+
+// isServeMux reports if recv is the net/http package, http.DefaultServeMux,
+// or a known router.
+func (serveMuxRoutes) isServeMux(recv ast.Expr, s *RouteScope) bool {
+	if pkg := s.Package(recv); pkg != "" {
+		return pkg == "net/http"
+	}
+	if sel, ok := recv.(*ast.SelectorExpr); ok && s.Package(sel.X) == "net/http" {
+		return sel.Sel.Name == "DefaultServeMux"
+	}
+	return s.IsRouter(recv)
+}
+
+func (x serveMuxRoutes) Mount(c *ast.CallExpr, s *RouteScope) {
+	recv, name, ok := methodCall(c)
+	if !ok || name != "Handle" || len(c.Args) != 2 || !x.isServeMux(recv, s) {
+		return
+	}
+	if !isStripPrefix(c.Args[1]) {
+		return
+	}
+	strip := c.Args[1].(*ast.CallExpr)
+	if prefix, ok := stringLit(strip.Args[0]); ok {
+		s.SetPrefix(strip.Args[1], joinPath(s.Prefix(recv), prefix))
+	}
+}
+
+func (x serveMuxRoutes) Routes(c *ast.CallExpr, s *RouteScope) []Route {
+	recv, name, ok := methodCall(c)
+	if !ok || (name != "Handle" && name != "HandleFunc") || len(c.Args) != 2 {
+		return nil
+	}
+	// Package-level Handle functions from other packages and methods on other
+	// types aren't ServeMux registrations.
+	if !x.isServeMux(recv, s) {
+		return nil
+	}
+	// Mounted routers are added by Mount.
+	if isStripPrefix(c.Args[1]) {
+		return nil
+	}
+
+	pattern, ok := stringLit(c.Args[0])
+	if !ok {
+		return nil
+	}
+	method, path, ok := parsePattern(pattern)
+	if !ok {
+		s.l.dbg("serveMuxRoutes: ignoring pattern %q", pattern)
+		return nil
+	}
+	return []Route{{Method: method, Path: joinPath(s.Prefix(recv), path)}}
+}
+
+// isStripPrefix reports if e is a call to http.StripPrefix.
+func isStripPrefix(e ast.Expr) bool {
+	c, ok := e.(*ast.CallExpr)
+	if !ok || len(c.Args) != 2 {
+		return false
+	}
+	_, name, ok := methodCall(c)
+	return ok && name == "StripPrefix"
+}
+
+// chiRoutes finds routes registered with github.com/go-chi/chi on routers
+// created with chi.NewRouter(), parameters with the chi.Router type, and the
+// parameters of functions passed to Route and Group:
+//
+//	r.Get("/path", h)
+//	r.Method("GET", "/path", h)
+//	r.Handle("/path", h)
+//	r.Route("/prefix", func(r chi.Router) { ... })
+//	r.Group(func(r chi.Router) { ... })
+//	r.With(middleware).Get("/path", h)
+//	r.Mount("/prefix", subRouter)
+type chiRoutes struct{}
+
+func (chiRoutes) Imports(path string) bool { return strings.HasPrefix(path, "github.com/go-chi/chi") }
+
+// RouterType reports if name is a router type in the chi package.
+func (chiRoutes) RouterType(name string) bool { return name == "Router" || name == "Mux" }
+
+func (x chiRoutes) Router(c *ast.CallExpr, s *RouteScope) (string, bool) {
+	recv, name, ok := methodCall(c)
+	if !ok {
+		return "", false
+	}
+	if pkg := s.Package(recv); pkg != "" {
+		return "", x.Imports(pkg) && (name == "NewRouter" || name == "NewMux")
+	}
+	if !s.IsRouter(recv) {
+		return "", false
+	}
+	switch name {
+	case "With", "Group":
+		return s.Prefix(recv), true
+	case "Route":
+		if len(c.Args) == 2 {
+			if p, ok := stringLit(c.Args[0]); ok {
+				return joinPath(s.Prefix(recv), p), true
+			}
+		}
+	}
+	return "", false
+}
+
+func (chiRoutes) Mount(c *ast.CallExpr, s *RouteScope) {
+	recv, name, ok := methodCall(c)
+	if !ok || s.Package(recv) != "" || !s.IsRouter(recv) {
+		return
+	}
+	switch name {
+	case "Route", "Mount":
+		if len(c.Args) != 2 {
+			return
+		}
+		p, ok := stringLit(c.Args[0])
+		if !ok {
+			return
+		}
+		if name == "Route" {
+			if param := funcLitParam(c.Args[1]); param != nil {
+				s.SetPrefix(param, joinPath(s.Prefix(recv), p))
+			}
+			return
+		}
+		s.SetPrefix(c.Args[1], joinPath(s.Prefix(recv), p))
+	case "Group":
+		if len(c.Args) == 1 {
+			if param := funcLitParam(c.Args[0]); param != nil {
+				s.SetPrefix(param, s.Prefix(recv))
+			}
+		}
+	}
+}
+
+func (chiRoutes) Routes(c *ast.CallExpr, s *RouteScope) []Route {
+	// Only use calls on routers, and not on e.g. HTTP clients or caches with a
+	// Get("key", ..) method.
+	recv, name, ok := methodCall(c)
+	if !ok || s.Package(recv) != "" || !s.IsRouter(recv) {
+		return nil
+	}
+
+	var method, path string
+	switch name {
+	case "Handle", "HandleFunc":
+		if len(c.Args) != 2 {
+			return nil
+		}
+		// chi 5.1 also accepts ServeMux-style "METHOD /path" patterns.
+		pattern, ok := stringLit(c.Args[0])
+		if !ok {
+			return nil
+		}
+		if method, path, ok = parsePattern(pattern); !ok {
+			return nil
+		}
+	case "Method", "MethodFunc":
+		if len(c.Args) != 3 {
+			return nil
+		}
+		m, ok1 := stringLit(c.Args[0])
+		p, ok2 := stringLit(c.Args[1])
+		if !ok1 || !ok2 {
+			return nil
+		}
+		method, path = strings.ToUpper(m), p
+	default:
+		m := strings.ToUpper(name)
+		if len(c.Args) != 2 || !sliceutil.Contains(allMethods, m) {
+			return nil
+		}
+		p, ok := stringLit(c.Args[0])
+		if !ok {
+			return nil
+		}
+		method, path = m, p
+	}
+	return []Route{{Method: method, Path: joinPath(s.Prefix(recv), path)}}
+}
+
+// gorillaRoutes finds routes registered with github.com/gorilla/mux:
+//
+//	r.HandleFunc("/path", h).Methods("GET", "POST")
+//	r.Path("/path").Methods("GET").HandlerFunc(h)
+//	s := r.PathPrefix("/prefix").Subrouter()
+type gorillaRoutes struct{}
+
+func (gorillaRoutes) Imports(path string) bool { return path == "github.com/gorilla/mux" }
+
+// RouterType reports if name is a router type in the gorilla/mux package.
+func (gorillaRoutes) RouterType(name string) bool { return name == "Router" }
+
+func (x gorillaRoutes) Router(c *ast.CallExpr, s *RouteScope) (string, bool) {
+	recv, calls := callChain(c)
+	if recv == nil {
+		return "", false
+	}
+	if pkg := s.Package(recv); pkg != "" {
+		// mux.NewRouter(), optionally followed by options such as
+		// StrictSlash(true).
+		if _, name, _ := methodCall(calls[0]); !x.Imports(pkg) || name != "NewRouter" {
+			return "", false
+		}
+		for _, cc := range calls[1:] {
+			switch _, name, _ := methodCall(cc); name {
+			case "StrictSlash", "SkipClean", "UseEncodedPath":
+			default:
+				return "", false
+			}
+		}
+		return "", true
+	}
+	if !s.IsRouter(recv) {
+		return "", false
+	}
+	if _, name, _ := methodCall(calls[len(calls)-1]); name != "Subrouter" {
+		return "", false
+	}
+
+	prefix := s.Prefix(recv)
+	for _, cc := range calls {
+		if _, name, _ := methodCall(cc); (name == "PathPrefix" || name == "Path") && len(cc.Args) == 1 {
+			if p, ok := stringLit(cc.Args[0]); ok {
+				prefix = joinPath(prefix, p)
+			}
+		}
+	}
+	return prefix, true
+}
+
+func (gorillaRoutes) Mount(*ast.CallExpr, *RouteScope) {}
+
+func (gorillaRoutes) Routes(c *ast.CallExpr, s *RouteScope) []Route {
+	recv, calls := callChain(c)
+	if recv == nil || s.Package(recv) != "" || !s.IsRouter(recv) {
+		return nil
+	}
+
+	var (
+		path       string
+		hasPath    bool
+		hasHandler bool
+		methods    []string
+	)
+	for _, cc := range calls {
+		_, name, _ := methodCall(cc)
+		switch name {
+		case "HandleFunc", "Handle", "Path":
+			if (name == "Path" && len(cc.Args) != 1) || (name != "Path" && len(cc.Args) != 2) {
+				return nil
+			}
+			p, ok := stringLit(cc.Args[0])
+			if !ok {
+				return nil
+			}
+			path, hasPath = p, true
+			hasHandler = hasHandler || name != "Path"
+		case "HandlerFunc", "Handler":
+			hasHandler = true
+		case "Methods":
+			for _, a := range cc.Args {
+				if m, ok := stringLit(a); ok {
+					methods = append(methods, strings.ToUpper(m))
+				}
+			}
+		case "Subrouter":
+			return nil
+		}
+	}
+	if !hasPath || !hasHandler {
+		return nil
+	}
+
+	path = joinPath(s.Prefix(recv), path)
+	if len(methods) == 0 {
+		return []Route{{Path: path}}
+	}
+	routes := make([]Route, 0, len(methods))
+	for _, m := range methods {
+		routes = append(routes, Route{Method: m, Path: path})
+	}
+	return routes
+}
+
+// echoRoutes finds routes registered with github.com/labstack/echo:
+//
+//	e.GET("/path/:id", h)
+//	e.Any("/path", h)
+//	e.Match([]string{"GET", "POST"}, "/path", h)
+//	e.Add("GET", "/path", h)
+//	g := e.Group("/prefix")
+type echoRoutes struct{}
+
+func (echoRoutes) Imports(path string) bool {
+	return strings.HasPrefix(path, "github.com/labstack/echo")
+}
+
+// RouterType reports if name is a router type in the echo package.
+func (echoRoutes) RouterType(name string) bool { return name == "Echo" || name == "Group" }
+
+func (x echoRoutes) Router(c *ast.CallExpr, s *RouteScope) (string, bool) {
+	return groupCall(c, s, x.Imports, "New")
+}
+
+func (echoRoutes) Mount(*ast.CallExpr, *RouteScope) {}
+
+func (echoRoutes) Routes(c *ast.CallExpr, s *RouteScope) []Route { return methodRoutes(c, s, "Add") }
+
+// ginRoutes finds routes registered with github.com/gin-gonic/gin:
+//
+//	r.GET("/path/:id", h)
+//	r.Any("/path", h)
+//	r.Match([]string{"GET", "POST"}, "/path", h)
+//	r.Handle("GET", "/path", h)
+//	g := r.Group("/prefix")
+type ginRoutes struct{}
+
+func (ginRoutes) Imports(path string) bool { return path == "github.com/gin-gonic/gin" }
+
+// RouterType reports if name is a router type in the gin package.
+func (ginRoutes) RouterType(name string) bool {
+	return name == "Engine" || name == "RouterGroup" || name == "IRouter" || name == "IRoutes"
+}
+
+func (x ginRoutes) Router(c *ast.CallExpr, s *RouteScope) (string, bool) {
+	return groupCall(c, s, x.Imports, "New", "Default")
+}
+
+func (ginRoutes) Mount(*ast.CallExpr, *RouteScope) {}
+
+func (ginRoutes) Routes(c *ast.CallExpr, s *RouteScope) []Route { return methodRoutes(c, s, "Handle") }
+
+// groupCall gets the prefix for the Group("/prefix", ..) method used by echo and
+// gin, or a blank prefix for calls to one of the constructors in a package for
+// which imports is true.
+func groupCall(c *ast.CallExpr, s *RouteScope, imports func(string) bool, constructors ...string) (string, bool) {
+	recv, name, ok := methodCall(c)
+	if !ok {
+		return "", false
+	}
+	if pkg := s.Package(recv); pkg != "" {
+		return "", imports(pkg) && sliceutil.Contains(constructors, name)
+	}
+	if name != "Group" || len(c.Args) == 0 || !s.IsRouter(recv) {
+		return "", false
+	}
+	p, ok := stringLit(c.Args[0])
+	if !ok {
+		return "", false
+	}
+	return joinPath(s.Prefix(recv), colonPath(p)), true
+}
+
+// methodRoutes gets the routes for the methods used by echo and gin; add is the
+// name of the method to add a route with the method as a string.
+func methodRoutes(c *ast.CallExpr, s *RouteScope, add string) []Route {
+	recv, name, ok := methodCall(c)
+	if !ok || len(c.Args) < 2 || s.Package(recv) != "" || !s.IsRouter(recv) {
+		return nil
+	}
+
+	var (
+		methods []string
+		pathArg = c.Args[0]
+	)
+	switch {
+	case sliceutil.Contains(allMethods, name):
+		methods = []string{name}
+	case name == "Any":
+		methods = []string{""}
+	case name == add || name == "Match":
+		if len(c.Args) < 3 {
+			return nil
+		}
+		pathArg = c.Args[1]
+		if name == add {
+			m, ok := stringLit(c.Args[0])
+			if !ok {
+				return nil
+			}
+			methods = []string{strings.ToUpper(m)}
+			break
+		}
+		lit, ok := c.Args[0].(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+		for _, elt := range lit.Elts {
+			if m, ok := stringLit(elt); ok {
+				methods = append(methods, strings.ToUpper(m))
+			}
+		}
+	default:
+		return nil
+	}
+
+	p, ok := stringLit(pathArg)
+	if !ok {
+		return nil
+	}
+	path := joinPath(s.Prefix(recv), colonPath(p))
+	routes := make([]Route, 0, len(methods))
+	for _, m := range methods {
+		routes = append(routes, Route{Method: m, Path: path})
+	}
+	return routes
+}
@@ -0,0 +1,461 @@
+package docparse
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Route is a route registered with a router in the source code.
+type Route struct {
+	Method string         // HTTP method; blank means any method.
+	Path   string         // Request path, with wildcards as {name}.
+	Pos    token.Position // Location of the registration.
+}
+
+var (
+	reRouteMethod  = regexp.MustCompile(`^[A-Z]+$`)
+	reMajorVersion = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// RouteExtractor recognizes the route registrations of a router package.
+type RouteExtractor interface {
+	// Imports reports if the router is in the package with this import path;
+	// the extractor is only used for files importing it.
+	Imports(path string) bool
+
+	// Router gets the path prefix of the router returned by c, if c creates
+	// a router or route group (e.g. echo's e.Group("/api")).
+	Router(c *ast.CallExpr, s *RouteScope) (prefix string, ok bool)
+
+	// Mount sets the path prefix of routers which are passed to c, such as
+	// mounted sub-routers or the parameter of a function literal.
+	Mount(c *ast.CallExpr, s *RouteScope)
+
+	// Routes gets the routes registered by c. The paths should include the
+	// prefix of the router; the Pos is set by the caller.
+	Routes(c *ast.CallExpr, s *RouteScope) []Route
+}
+
+// RouteExtractors are used to find the route registrations if Config.Routes is
+// set.
+var RouteExtractors = []RouteExtractor{
+	chiRoutes{}, gorillaRoutes{}, echoRoutes{}, ginRoutes{}, serveMuxRoutes{},
+}
+
+// RouteScope tracks the path prefixes of routers in a file.
+type RouteScope struct {
+	extractors []RouteExtractor
+	imports    map[string]string // Package name -> import path.
+	prefixes   map[interface{}]string
+	l          *Loader // For debug output; may be nil.
+}
+
+// Prefix gets the path prefix of the router expression e; this is blank if
+// it's not known.
+func (s *RouteScope) Prefix(e ast.Expr) string {
+	p, _ := s.router(e)
+	return p
+}
+
+// IsRouter reports if e is a known router: a variable or field a router was
+// assigned to, a mounted router, a function parameter with a router type, or a
+// call which returns a router.
+func (s *RouteScope) IsRouter(e ast.Expr) bool {
+	_, ok := s.router(e)
+	return ok
+}
+
+func (s *RouteScope) router(e ast.Expr) (string, bool) {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return s.router(e.X)
+	case *ast.StarExpr:
+		return s.router(e.X)
+	case *ast.UnaryExpr:
+		return s.router(e.X)
+	case *ast.CallExpr:
+		for _, x := range s.extractors {
+			if p, ok := x.Router(e, s); ok {
+				return p, true
+			}
+		}
+		return "", false
+	}
+	k := scopeKey(e)
+	if k == nil {
+		return "", false
+	}
+	p, ok := s.prefixes[k]
+	return p, ok
+}
+
+// SetPrefix sets the path prefix of the router expression e.
+func (s *RouteScope) SetPrefix(e ast.Expr, prefix string) {
+	if k := scopeKey(e); k != nil {
+		s.prefixes[k] = prefix
+	}
+}
+
+// Package gets the import path if e refers to an imported package.
+func (s *RouteScope) Package(e ast.Expr) string {
+	if id, ok := e.(*ast.Ident); ok && id.Obj == nil {
+		return s.imports[id.Name]
+	}
+	return ""
+}
+
+// routerTyper is implemented by RouteExtractors which can recognize router
+// types, so that function parameters such as "r chi.Router" are known routers.
+type routerTyper interface {
+	RouterType(name string) bool
+}
+
+// isRouterType reports if the type expression t is a router type of one of the
+// extractors.
+func (s *RouteScope) isRouterType(t ast.Expr) bool {
+	if st, ok := t.(*ast.StarExpr); ok {
+		t = st.X
+	}
+	sel, ok := t.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg := s.Package(sel.X)
+	if pkg == "" {
+		return false
+	}
+	for _, x := range s.extractors {
+		if rt, ok := x.(routerTyper); ok && x.Imports(pkg) && rt.RouterType(sel.Sel.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRouters adds names as routers without a prefix if t is a router type,
+// unless they already have a prefix (such as the parameter of a function
+// passed to chi's Route).
+func (s *RouteScope) addRouters(t ast.Expr, names []*ast.Ident) {
+	if t == nil || !s.isRouterType(t) {
+		return
+	}
+	for _, n := range names {
+		if !s.IsRouter(n) {
+			s.SetPrefix(n, "")
+		}
+	}
+}
+
+// scopeKey gets the key for the expression e in RouteScope.prefixes; this is the
+// object for identifiers (so shadowed variables are distinct), and the
+// expression as a string for struct fields.
+func scopeKey(e ast.Expr) interface{} {
+	switch e := e.(type) {
+	case *ast.Ident:
+		if e.Obj != nil {
+			return e.Obj
+		}
+		return e.Name
+	case *ast.ParenExpr:
+		return scopeKey(e.X)
+	case *ast.StarExpr:
+		return scopeKey(e.X)
+	case *ast.UnaryExpr:
+		return scopeKey(e.X)
+	case *ast.SelectorExpr:
+		return types.ExprString(e)
+	}
+	return nil
+}
+
+// collectRoutes finds all route registrations in f with the RouteExtractors
+// for the packages f imports.
+//
+// This is done in two passes: the first pass records the prefixes of routers
+// assigned to variables and of mounted routers, and the second pass collects
+// the routes. Calls are only used once, by the first extractor which finds any
+// routes.
+func (l *Loader) collectRoutes(fset *token.FileSet, f *ast.File) []Route {
+	s := &RouteScope{
+		l:        l,
+		imports:  make(map[string]string),
+		prefixes: make(map[interface{}]string),
+	}
+	for _, imp := range f.Imports {
+		p := strings.Trim(imp.Path.Value, `"`)
+		name := importName(p)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		s.imports[name] = p
+
+		for _, x := range RouteExtractors {
+			if x.Imports(p) && !extractorIn(s.extractors, x) {
+				s.extractors = append(s.extractors, x)
+			}
+		}
+	}
+	if len(s.extractors) == 0 {
+		return nil
+	}
+
+	assign := func(lhs []ast.Expr, rhs []ast.Expr) {
+		if len(lhs) != len(rhs) {
+			return
+		}
+		for i := range rhs {
+			if c, ok := rhs[i].(*ast.CallExpr); ok {
+				for _, x := range s.extractors {
+					if p, ok := x.Router(c, s); ok {
+						s.SetPrefix(lhs[i], p)
+						break
+					}
+				}
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			assign(n.Lhs, n.Rhs)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(n.Names))
+			for i := range n.Names {
+				lhs[i] = n.Names[i]
+			}
+			assign(lhs, n.Values)
+			s.addRouters(n.Type, n.Names)
+		case *ast.FuncType:
+			if n.Params != nil {
+				for _, f := range n.Params.List {
+					s.addRouters(f.Type, f.Names)
+				}
+			}
+		case *ast.CallExpr:
+			for _, x := range s.extractors {
+				x.Mount(n, s)
+			}
+		}
+		return true
+	})
+
+	var (
+		routes []Route
+		seen   = make(map[token.Pos]struct{})
+	)
+	ast.Inspect(f, func(n ast.Node) bool {
+		c, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		// Method chains such as r.HandleFunc(..).Methods(..) start at the same
+		// position as the calls in them.
+		if _, ok := seen[c.Pos()]; ok {
+			return true
+		}
+		for _, x := range s.extractors {
+			r := x.Routes(c, s)
+			if len(r) == 0 {
+				continue
+			}
+			seen[c.Pos()] = struct{}{}
+			for i := range r {
+				r[i].Pos = fset.Position(c.Pos())
+			}
+			routes = append(routes, r...)
+			break
+		}
+		return true
+	})
+	return routes
+}
+
+// importName gets the default package name for the import path p; for
+// "github.com/go-chi/chi/v5" this is "chi".
+func importName(p string) string {
+	name := p[strings.LastIndex(p, "/")+1:]
+	if reMajorVersion.MatchString(name) && strings.Contains(p, "/") {
+		p = p[:strings.LastIndex(p, "/")]
+		name = p[strings.LastIndex(p, "/")+1:]
+	}
+	return name
+}
+
+func extractorIn(list []RouteExtractor, x RouteExtractor) bool {
+	for _, l := range list {
+		if l == x {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath joins the router prefix and path.
+func joinPath(prefix, path string) string {
+	if prefix != "" && (path == "" || path == "/") {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+// stringLit gets the value of the string literal e.
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// parsePattern parses a net/http.ServeMux pattern, which is in the form of
+// "[METHOD ][HOST]/[PATH]". The host is ignored.
+func parsePattern(pattern string) (method, path string, ok bool) {
+	pattern = strings.TrimSpace(pattern)
+	if i := strings.IndexAny(pattern, " \t"); i > -1 {
+		method, pattern = pattern[:i], strings.TrimLeft(pattern[i:], " \t")
+		if !reRouteMethod.MatchString(method) {
+			return "", "", false
+		}
+	}
+
+	i := strings.Index(pattern, "/")
+	if i == -1 {
+		return "", "", false
+	}
+	return method, pattern[i:], true
+}
+
+// normalizePath removes the wildcard names from path, so that "/a/{id}" and
+// "/a/{name...}" compare as equal; a trailing {$} is removed.
+func normalizePath(path string) string {
+	path = strings.TrimSuffix(path, "{$}")
+	s := strings.Split(path, "/")
+	for i := range s {
+		if strings.HasPrefix(s[i], "{") && strings.HasSuffix(s[i], "}") {
+			s[i] = "{}"
+		}
+	}
+	return strings.Join(s, "/")
+}
+
+// routeMatches reports if the route r serves the endpoint with method and path.
+func routeMatches(r Route, method, path string) bool {
+	if normalizePath(r.Path) != normalizePath(path) {
+		return false
+	}
+	method = strings.ToUpper(method)
+	switch r.Method {
+	case "", method:
+		return true
+	case "GET":
+		return method == "HEAD"
+	}
+	return false
+}
+
+// CheckRoutes compares the routes registered in the source code with the
+// documented endpoints; it returns all routes without documentation, and all
+// endpoints which are documented but not registered.
+//
+// Config.Routes needs to be enabled for FindComments to collect the routes.
+func CheckRoutes(prog *Program) (undocumented []Route, unregistered []*Endpoint) {
+	// The routes may be registered with Config.Prefix, or the prefix may be
+	// added outside the application (e.g. by a proxy), so accept both.
+	matches := func(r Route, e *Endpoint) bool {
+		return routeMatches(r, e.Method, e.Path) ||
+			prog.Config.Prefix != "" && routeMatches(r, e.Method, prog.Config.Prefix+e.Path)
+	}
+
+	for _, r := range prog.Routes {
+		found := false
+		for _, e := range prog.Endpoints {
+			if matches(r, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			undocumented = append(undocumented, r)
+		}
+	}
+
+	for _, e := range prog.Endpoints {
+		found := false
+		for _, r := range prog.Routes {
+			if matches(r, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unregistered = append(unregistered, e)
+		}
+	}
+
+	return undocumented, unregistered
+}
+
+// WriteRoutes writes a report of the routes without documentation and the
+// documented endpoints which aren't registered. It returns an error if there
+// are any.
+func WriteRoutes(w io.Writer, prog *Program) error {
+	undocumented, unregistered := CheckRoutes(prog)
+
+	type problem struct {
+		pos token.Position
+		msg string
+	}
+	var problems []problem
+	for _, r := range undocumented {
+		method := r.Method
+		if method == "" {
+			method = "*"
+		}
+		problems = append(problems, problem{r.Pos, fmt.Sprintf("%s %s: route is not documented", method, r.Path)})
+	}
+	for _, e := range unregistered {
+		problems = append(problems, problem{e.Pos, fmt.Sprintf("%s %s: endpoint is not registered", e.Method, e.Path)})
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].pos.Filename != problems[j].pos.Filename {
+			return problems[i].pos.Filename < problems[j].pos.Filename
+		}
+		return problems[i].pos.Line < problems[j].pos.Line
+	})
+
+	for _, p := range problems {
+		if _, err := fmt.Fprintf(w, "%s:%d: %s\n", relFile(p.pos.Filename), p.pos.Line, p.msg); err != nil {
+			return err
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d undocumented routes and %d unregistered endpoints",
+			len(undocumented), len(unregistered))
+	}
+	return nil
+}
+
+// relFile gets the path of file relative to the working directory, if it's in
+// the working directory.
+func relFile(file string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return file
+}
@@ -0,0 +1,287 @@
+package docparse
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/teamwork/test"
+	"github.com/teamwork/test/diff"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantMethod string
+		wantPath   string
+		wantOK     bool
+	}{
+		{"/", "", "/", true},
+		{"/objects/{id}", "", "/objects/{id}", true},
+		{"GET /objects/{id}", "GET", "/objects/{id}", true},
+		{"POST  \t/objects", "POST", "/objects", true},
+		{"GET example.com/objects/", "GET", "/objects/", true},
+		{"example.com/", "", "/", true},
+
+		{"", "", "", false},
+		{"get /objects", "", "", false},
+		{"GET objects", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			method, path, ok := parsePattern(tt.in)
+			if ok != tt.wantOK {
+				t.Fatalf("ok is %t", ok)
+			}
+			if method != tt.wantMethod {
+				t.Errorf("wrong method\nout:  %q\nwant: %q", method, tt.wantMethod)
+			}
+			if path != tt.wantPath {
+				t.Errorf("wrong path\nout:  %q\nwant: %q", path, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		route  Route
+		method string
+		path   string
+		want   bool
+	}{
+		{Route{Method: "GET", Path: "/a"}, "GET", "/a", true},
+		{Route{Method: "GET", Path: "/a"}, "get", "/a", true},
+		{Route{Method: "GET", Path: "/a"}, "HEAD", "/a", true},
+		{Route{Method: "", Path: "/a"}, "DELETE", "/a", true},
+		{Route{Method: "GET", Path: "/a/{objectID}"}, "GET", "/a/{id}", true},
+		{Route{Method: "GET", Path: "/a/{path...}"}, "GET", "/a/{path}", true},
+		{Route{Method: "GET", Path: "/a/{$}"}, "GET", "/a/", true},
+
+		{Route{Method: "GET", Path: "/a"}, "POST", "/a", false},
+		{Route{Method: "HEAD", Path: "/a"}, "GET", "/a", false},
+		{Route{Method: "GET", Path: "/a"}, "GET", "/a/", false},
+		{Route{Method: "GET", Path: "/a/{id}"}, "GET", "/a/b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.route.Method+" "+tt.route.Path, func(t *testing.T) {
+			out := routeMatches(tt.route, tt.method, tt.path)
+			if out != tt.want {
+				t.Errorf("routeMatches(%v, %q, %q) = %t", tt.route, tt.method, tt.path, out)
+			}
+		})
+	}
+}
+
+func TestWriteRoutes(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.Packages = []string{"./testdata/src/routes"}
+	prog.Config.StructTag = "json"
+	prog.Config.Routes = true
+	prog.Config.Output = WriteRoutes
+
+	buf := new(bytes.Buffer)
+	err := FindComments(buf, prog)
+	if !test.ErrorContains(err, "1 undocumented routes and 1 unregistered endpoints") {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	if len(prog.Routes) != 3 {
+		t.Errorf("len(prog.Routes) == %d", len(prog.Routes))
+	}
+
+	want := "testdata/src/routes/routes.go:19: DELETE /objects/{id}: endpoint is not registered\n" +
+		"testdata/src/routes/routes.go:29: GET /objects: route is not documented\n"
+	if d := diff.TextDiff(want, buf.String()); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestCheckRoutesPrefix(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.Prefix = "/v1"
+	prog.Endpoints = []*Endpoint{
+		{Method: "GET", Path: "/objects"},
+		{Method: "GET", Path: "/objects/{id}"},
+		{Method: "DELETE", Path: "/objects/{id}"},
+	}
+	prog.Routes = []Route{
+		{Method: "GET", Path: "/v1/objects"},
+		{Method: "GET", Path: "/objects/{objectID}"},
+		{Method: "POST", Path: "/v1/objects"},
+	}
+
+	undocumented, unregistered := CheckRoutes(prog)
+	if len(undocumented) != 1 || undocumented[0].Method != "POST" {
+		t.Errorf("wrong undocumented: %v", undocumented)
+	}
+	if len(unregistered) != 1 || unregistered[0].Method != "DELETE" {
+		t.Errorf("wrong unregistered: %v", unregistered)
+	}
+}
+
+func TestCollectRoutes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"servemux", `
+			import "net/http"
+
+			func register(mux *http.ServeMux, api *http.ServeMux) {
+				mux.HandleFunc("GET /objects/{id}", get)
+				mux.Handle("/api/", http.StripPrefix("/api", api))
+				api.HandleFunc("POST /objects", create)
+				http.Handle("/static/", files)
+			}`,
+			[]string{"GET /objects/{id}", "POST /api/objects", "* /static/"},
+		},
+		{"servemux-other-pkg", `
+			import (
+				"net/http"
+				"example.com/other"
+			)
+
+			func register() {
+				other.HandleFunc("/x", get)
+			}`,
+			nil,
+		},
+		{"chi", `
+			import "github.com/go-chi/chi/v5"
+
+			func register() {
+				r := chi.NewRouter()
+				r.Get("/", index)
+				r.Route("/objects", func(r chi.Router) {
+					r.Post("/", create)
+					r.Route("/{id}", func(r chi.Router) {
+						r.With(auth).Get("/", get)
+						r.Method("delete", "/", remove)
+					})
+				})
+				r.Group(func(r chi.Router) {
+					r.Put("/settings", update)
+				})
+
+				admin := chi.NewRouter()
+				admin.HandleFunc("PATCH /users", users)
+				r.Mount("/admin", admin)
+			}`,
+			[]string{"GET /", "POST /objects", "GET /objects/{id}", "DELETE /objects/{id}",
+				"PUT /settings", "PATCH /admin/users"},
+		},
+		{"chi-receivers", `
+			import (
+				"net/http"
+
+				"github.com/go-chi/chi/v5"
+			)
+
+			func register(r chi.Router, m *chi.Mux, client *http.Client, cache *Cache) {
+				r.Get("/objects", list)
+				m.Post("/objects", create)
+				cache.Get("/objects", fallback)
+				cache.Route("/x", func(c *Cache) {
+					c.Get("/y", get)
+				})
+				_, _ = client.Get("/objects")
+
+				var sub chi.Router
+				sub.Delete("/objects/{id}", remove)
+			}`,
+			[]string{"GET /objects", "POST /objects", "DELETE /objects/{id}"},
+		},
+		{"other-receivers", `
+			import (
+				"net/http"
+
+				"github.com/gin-gonic/gin"
+				"github.com/gorilla/mux"
+				"github.com/labstack/echo/v4"
+			)
+
+			func register(cache *Cache, client *Client) {
+				cache.Handle("/objects", h)
+				cache.HandleFunc("GET /objects", get)
+				cache.HandleFunc("/objects", list).Methods("GET")
+				cache.Path("/objects/{id}").Methods("DELETE").HandlerFunc(remove)
+				cache.GET("/objects", get)
+				cache.Handle("GET", "/objects", get)
+				client.Group("/api").POST("/objects", create)
+				client.PathPrefix("/api").Subrouter().HandleFunc("/users", users)
+			}`,
+			nil,
+		},
+		{"gorilla", `
+			import "github.com/gorilla/mux"
+
+			func register() {
+				r := mux.NewRouter().StrictSlash(true)
+				r.HandleFunc("/objects", list).Methods("GET", "HEAD")
+				r.Path("/objects/{id:[0-9]+}").Methods("DELETE").HandlerFunc(remove)
+				r.Handle("/any", h)
+
+				api := r.PathPrefix("/api").Subrouter()
+				api.HandleFunc("/users", users).Methods("POST").Name("users")
+			}`,
+			[]string{"GET /objects", "HEAD /objects", "DELETE /objects/{id:[0-9]+}", "* /any", "POST /api/users"},
+		},
+		{"echo", `
+			import "github.com/labstack/echo/v4"
+
+			func register() {
+				e := echo.New()
+				e.GET("/objects/:id", get)
+				e.Any("/any", h)
+
+				g := e.Group("/api", auth)
+				g.Match([]string{"PUT", "PATCH"}, "/users/:id", update)
+				g.Add("DELETE", "/files/*", remove)
+			}`,
+			[]string{"GET /objects/{id}", "* /any", "PUT /api/users/{id}", "PATCH /api/users/{id}",
+				"DELETE /api/files/{path...}"},
+		},
+		{"gin", `
+			import "github.com/gin-gonic/gin"
+
+			func register() {
+				r := gin.Default()
+				v1 := r.Group("/v1")
+				{
+					v1.POST("/objects", create)
+					v1.Handle("GET", "/objects/:id", get)
+				}
+				r.GET("/files/*filepath", files)
+			}`,
+			[]string{"POST /v1/objects", "GET /v1/objects/{id}", "GET /files/{filepath...}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "routes.go", "package routes\n"+tt.in, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out []string
+			for _, r := range NewLoader().collectRoutes(fset, f) {
+				m := r.Method
+				if m == "" {
+					m = "*"
+				}
+				out = append(out, m+" "+r.Path)
+			}
+			if !reflect.DeepEqual(tt.want, out) {
+				t.Errorf("\n%v", diff.Diff(tt.want, out))
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package docparse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+// Security scheme types.
+const (
+	SecurityBasic  = "basic"
+	SecurityAPIKey = "apiKey"
+	SecurityBearer = "bearer"
+	SecurityOAuth2 = "oauth2"
+)
+
+// OAuth2 flows, as named in OpenAPI 2.
+const (
+	FlowImplicit    = "implicit"
+	FlowPassword    = "password"
+	FlowApplication = "application"
+	FlowAccessCode  = "accessCode"
+)
+
+// SecurityScheme describes a method to authenticate.
+type SecurityScheme struct {
+	Type string // basic, apiKey, bearer, or oauth2.
+
+	In   string // Location of the API key: header or query.
+	Name string // Header or query parameter name of the API key.
+
+	BearerFormat string // Format of the bearer token, e.g. "JWT" (optional).
+
+	Flow             string   // OAuth2 flow.
+	AuthorizationURL string   // OAuth2 authorization URL.
+	TokenURL         string   // OAuth2 token URL.
+	Scopes           []string // Available OAuth2 scopes.
+}
+
+// SecurityRequirement is a security scheme an endpoint accepts.
+type SecurityRequirement struct {
+	Name   string   // Name of the security scheme.
+	Scopes []string // Required OAuth2 scopes.
+}
+
+const refNone = "{none}"
+
+// ParseSecurityScheme parses a security scheme from the configuration file. The
+// syntax is:
+//
+//	name basic
+//	name apikey header|query param-name
+//	name bearer [format]
+//	name oauth2 implicit authorization-url [scopes..]
+//	name oauth2 password|application token-url [scopes..]
+//	name oauth2 accessCode authorization-url token-url [scopes..]
+func ParseSecurityScheme(line []string) (string, SecurityScheme, error) {
+	if len(line) < 2 {
+		return "", SecurityScheme{}, fmt.Errorf("need at least a name and type: %q",
+			strings.Join(line, " "))
+	}
+
+	name, args := line[0], line[2:]
+	var s SecurityScheme
+	switch strings.ToLower(line[1]) {
+	case "basic":
+		s.Type = SecurityBasic
+		if len(args) != 0 {
+			return "", s, fmt.Errorf("basic: unexpected arguments: %q", strings.Join(args, " "))
+		}
+
+	case "apikey":
+		s.Type = SecurityAPIKey
+		if len(args) != 2 {
+			return "", s, fmt.Errorf("apikey: need location and name: %q", strings.Join(args, " "))
+		}
+		s.In, s.Name = args[0], args[1]
+		if s.In != "header" && s.In != "query" {
+			return "", s, fmt.Errorf("apikey: location must be header or query, not %q", s.In)
+		}
+
+	case "bearer":
+		s.Type = SecurityBearer
+		switch len(args) {
+		case 0:
+		case 1:
+			s.BearerFormat = args[0]
+		default:
+			return "", s, fmt.Errorf("bearer: unexpected arguments: %q", strings.Join(args, " "))
+		}
+
+	case "oauth2":
+		s.Type = SecurityOAuth2
+		if len(args) < 1 {
+			return "", s, fmt.Errorf("oauth2: need a flow")
+		}
+		s.Flow, args = args[0], args[1:]
+
+		var nURL int
+		switch s.Flow {
+		case FlowImplicit, FlowPassword, FlowApplication:
+			nURL = 1
+		case FlowAccessCode:
+			nURL = 2
+		default:
+			return "", s, fmt.Errorf("oauth2: unknown flow %q", s.Flow)
+		}
+		if len(args) < nURL {
+			return "", s, fmt.Errorf("oauth2: %s flow needs %d URLs", s.Flow, nURL)
+		}
+
+		switch s.Flow {
+		case FlowImplicit:
+			s.AuthorizationURL = args[0]
+		case FlowPassword, FlowApplication:
+			s.TokenURL = args[0]
+		case FlowAccessCode:
+			s.AuthorizationURL, s.TokenURL = args[0], args[1]
+		}
+		s.Scopes = args[nURL:]
+
+	default:
+		return "", s, fmt.Errorf("unknown security scheme type %q", line[1])
+	}
+
+	return name, s, nil
+}
+
+// ParseSecurity parses a security requirement as "name [scopes..]". The scheme
+// must be defined in the configuration.
+func ParseSecurity(prog *Program, value string) (SecurityRequirement, error) {
+	f := strings.Fields(value)
+	if len(f) == 0 {
+		return SecurityRequirement{}, fmt.Errorf("no security scheme")
+	}
+
+	req := SecurityRequirement{Name: f[0], Scopes: f[1:]}
+	s, ok := prog.Config.SecurityScheme[req.Name]
+	if !ok {
+		return req, fmt.Errorf("unknown security scheme %q", req.Name)
+	}
+
+	if s.Type != SecurityOAuth2 && len(req.Scopes) > 0 {
+		return req, fmt.Errorf("security scheme %q is not oauth2 and can't have scopes", req.Name)
+	}
+	for _, sc := range req.Scopes {
+		if !sliceutil.Contains(s.Scopes, sc) {
+			return req, fmt.Errorf("scope %q not defined for security scheme %q", sc, req.Name)
+		}
+	}
+
+	return req, nil
+}
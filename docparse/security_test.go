@@ -0,0 +1,98 @@
+package docparse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teamwork/test"
+	"github.com/teamwork/test/diff"
+)
+
+func TestParseSecurityScheme(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantName string
+		want     SecurityScheme
+		wantErr  string
+	}{
+		{"basicAuth basic", "basicAuth", SecurityScheme{Type: SecurityBasic}, ""},
+		{"key apikey header X-Key", "key", SecurityScheme{Type: SecurityAPIKey, In: "header", Name: "X-Key"}, ""},
+		{"key apiKey query key", "key", SecurityScheme{Type: SecurityAPIKey, In: "query", Name: "key"}, ""},
+		{"jwt bearer", "jwt", SecurityScheme{Type: SecurityBearer}, ""},
+		{"jwt bearer JWT", "jwt", SecurityScheme{Type: SecurityBearer, BearerFormat: "JWT"}, ""},
+		{"o oauth2 implicit https://a", "o", SecurityScheme{
+			Type: SecurityOAuth2, Flow: FlowImplicit, AuthorizationURL: "https://a", Scopes: []string{},
+		}, ""},
+		{"o oauth2 application https://t read write", "o", SecurityScheme{
+			Type: SecurityOAuth2, Flow: FlowApplication, TokenURL: "https://t", Scopes: []string{"read", "write"},
+		}, ""},
+		{"o oauth2 accessCode https://a https://t read", "o", SecurityScheme{
+			Type: SecurityOAuth2, Flow: FlowAccessCode, AuthorizationURL: "https://a",
+			TokenURL: "https://t", Scopes: []string{"read"},
+		}, ""},
+
+		{"basicAuth", "", SecurityScheme{}, "need at least a name and type"},
+		{"basicAuth basic x", "", SecurityScheme{}, "unexpected arguments"},
+		{"key apikey cookie x", "", SecurityScheme{}, "must be header or query"},
+		{"key apikey header", "", SecurityScheme{}, "need location and name"},
+		{"o oauth2 foo", "", SecurityScheme{}, "unknown flow"},
+		{"o oauth2 accessCode https://a", "", SecurityScheme{}, "needs 2 URLs"},
+		{"x digest", "", SecurityScheme{}, "unknown security scheme type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			name, out, err := ParseSecurityScheme(strings.Fields(tt.in))
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if name != tt.wantName {
+				t.Errorf("wrong name\nout:  %q\nwant: %q", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(tt.want, out) {
+				t.Errorf("\n%v", diff.Diff(tt.want, out))
+			}
+		})
+	}
+}
+
+func TestParseSecurity(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.SecurityScheme = map[string]SecurityScheme{
+		"basicAuth": {Type: SecurityBasic},
+		"o":         {Type: SecurityOAuth2, Scopes: []string{"read", "write"}},
+	}
+
+	tests := []struct {
+		in      string
+		want    SecurityRequirement
+		wantErr string
+	}{
+		{"basicAuth", SecurityRequirement{Name: "basicAuth", Scopes: []string{}}, ""},
+		{"o read write", SecurityRequirement{Name: "o", Scopes: []string{"read", "write"}}, ""},
+
+		{"", SecurityRequirement{}, "no security scheme"},
+		{"nope", SecurityRequirement{}, "unknown security scheme"},
+		{"basicAuth read", SecurityRequirement{}, "can't have scopes"},
+		{"o admin", SecurityRequirement{}, "not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			out, err := ParseSecurity(prog, tt.in)
+			if !test.ErrorContains(err, tt.wantErr) {
+				t.Fatalf("wrong err\nout:  %#v\nwant: %#v\n", err, tt.wantErr)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(tt.want, out) {
+				t.Errorf("\n%v", diff.Diff(tt.want, out))
+			}
+		})
+	}
+}
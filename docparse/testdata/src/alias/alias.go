@@ -0,0 +1,24 @@
+package alias
+
+import (
+	"net/mail"
+
+	ei "github.com/teamwork/kommentaar/example/exampleimport"
+)
+
+type resp struct {
+	Foo  ei.Foo       `json:"foo"`
+	Addr mail.Address `json:"addr"`
+}
+
+// GET /aliased
+//
+// Response 200: ei.Foo
+
+// GET /bare
+//
+// Response 200: mail.Address
+
+// GET /nested
+//
+// Response 200: resp
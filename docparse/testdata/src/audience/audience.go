@@ -0,0 +1,48 @@
+package audience
+
+type pathParams struct {
+	ID int `path:"id"` // The ID. {internal}
+}
+
+type queryParams struct {
+	Page  int  `query:"page"`  // Page number.
+	Debug bool `query:"debug"` // Enable debug output {internal}
+}
+
+type object struct {
+	ID    int   `json:"id"`    // The ID. {required}
+	Owner owner `json:"owner"` // {internal, required}
+	Tags  []tag `json:"tags"`
+}
+
+type owner struct {
+	Name string `json:"name"`
+}
+
+type tag struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"` // {internal}
+}
+
+type stats struct {
+	Count int `json:"count"`
+}
+
+// GET /objects/{id} objects
+// Get an object.
+//
+// Path: pathParams
+// Query: queryParams
+// Response 200: object
+
+// GET /partner/objects objects
+// List objects for partners.
+//
+// Audience: internal partner
+// Response 200: []object
+
+// GET /stats stats
+// Get stats.
+//
+// Audience: internal
+// Response 200: stats
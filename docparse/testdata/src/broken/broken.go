@@ -0,0 +1,4 @@
+package broken
+
+type object struct {
+	ID int `json:"id"`
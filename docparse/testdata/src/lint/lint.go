@@ -0,0 +1,42 @@
+package lint
+
+type pathParams struct {
+	// Object ID.
+	ID int `path:"id"`
+
+	Version int `path:"version"`
+}
+
+type status string
+
+const (
+	statusActive   status = "active"
+	statusInactive status = "inactive"
+)
+
+type object struct {
+	// Object ID.
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`   // Kind of object {enum}
+	Status status `json:"status"` // Status of object {enum}
+	Hidden string `json:"-"`
+}
+
+// GET /objects/{version}/{id} objects
+// Get an object.
+//
+// Path: pathParams
+// Response 200: object
+// Response 404: {empty}
+
+// POST /objects objects
+//
+// Request body: object
+// Response 200: object
+
+// DELETE /objects/{id} objects
+// Delete an object.
+//
+// Path: pathParams
+// Response 204: {empty}
@@ -0,0 +1,57 @@
+package omitdoc
+
+type pathParams struct {
+	ID     int    `path:"id"`     // The ID.
+	Secret string `path:"secret"` // Secret key {omitdoc}
+}
+
+type queryParams struct {
+	Page int `query:"page"` // Page number.
+
+	// Enable debug output {omitdoc}
+	Debug bool `query:"debug"`
+}
+
+type resp struct {
+	ID int `json:"id"` // The ID. {required}
+
+	// Internal field {omitdoc} {required}
+	Internal string     `json:"internal"`
+	Nested   respNested `json:"nested"`
+}
+
+type respNested struct {
+	Name string `json:"name"`
+
+	// Trace ID {omitdoc}
+	Trace string `json:"trace"`
+}
+
+// GET /objects/{id}/{secret} objects
+// Get an object.
+//
+// Path: pathParams
+// Query: queryParams
+// Response 200: resp
+
+type wrapped struct {
+	Name string `json:"name"`
+
+	// Trace ID {omitdoc}
+	Trace string `json:"trace"`
+}
+
+type listed struct {
+	Name string `json:"name"`
+
+	// Trace ID {omitdoc}
+	Trace string `json:"trace"`
+}
+
+// GET /wrapped objects
+//
+// Response 200: [data:wrapped]
+
+// GET /listed objects
+//
+// Response 200: []listed
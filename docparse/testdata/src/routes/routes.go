@@ -0,0 +1,30 @@
+package routes
+
+import "net/http"
+
+type object struct {
+	ID int `json:"id"`
+}
+
+// GET /objects/{id}
+//
+// Response 200: object
+func get(w http.ResponseWriter, r *http.Request) {}
+
+// POST /objects
+//
+// Response 201: object
+func create(w http.ResponseWriter, r *http.Request) {}
+
+// DELETE /objects/{id}
+//
+// Response 204: {empty}
+func remove(w http.ResponseWriter, r *http.Request) {}
+
+func list(w http.ResponseWriter, r *http.Request) {}
+
+func register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /objects/{objectID}", get)
+	mux.Handle("POST example.com/objects", http.HandlerFunc(create))
+	http.HandleFunc("GET /objects", list)
+}
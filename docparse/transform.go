@@ -0,0 +1,302 @@
+package docparse
+
+import (
+	"strings"
+
+	"github.com/teamwork/utils/v2/goutil"
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+// Transform applies Config.Audience, and then runs Config.Transforms in order.
+// Config.Prefix is already added to the paths by Parse.
+//
+// FindComments calls this after parsing, before calling Config.Output; it
+// only needs to be called if Parse is used directly.
+func Transform(prog *Program) error {
+	if prog.Config.Audience != "" {
+		if err := FilterAudience(prog.Config.Audience)(prog); err != nil {
+			return err
+		}
+	}
+	for _, t := range prog.Config.Transforms {
+		if err := t(prog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tagged reports if e has tag; an empty tag matches all endpoints.
+func (e *Endpoint) tagged(tag string) bool {
+	return tag == "" || sliceutil.Contains(e.Tags, tag)
+}
+
+// DropTags removes all endpoints with one of tags, and all references which
+// are no longer used.
+func DropTags(tags ...string) func(*Program) error {
+	return func(prog *Program) error {
+		keep := prog.Endpoints[:0]
+		for _, e := range prog.Endpoints {
+			drop := false
+			for _, t := range tags {
+				if t != "" && e.tagged(t) {
+					drop = true
+					break
+				}
+			}
+			if !drop {
+				keep = append(keep, e)
+			}
+		}
+		prog.Endpoints = keep
+		return PruneReferences(prog)
+	}
+}
+
+// FilterAudience removes everything that isn't visible to audience:
+// endpoints with an Audience directive which doesn't list it, properties
+// marked with {internal} unless audience is "internal", and all references
+// which are no longer used.
+func FilterAudience(audience string) func(*Program) error {
+	return func(prog *Program) error {
+		keep := prog.Endpoints[:0]
+		for _, e := range prog.Endpoints {
+			if len(e.Audience) == 0 || sliceutil.Contains(e.Audience, audience) {
+				keep = append(keep, e)
+			}
+		}
+		prog.Endpoints = keep
+
+		if audience != paramInternal {
+			// Path parameters can't be removed without changing the path.
+			removeProperties(prog, func(p *Schema) bool { return p.Internal }, nil)
+		}
+		return PruneReferences(prog)
+	}
+}
+
+// PruneReferences removes all references which aren't used by any endpoint,
+// directly or through other references.
+func PruneReferences(prog *Program) error {
+	used := make(map[string]bool)
+	var (
+		walk   func(*Schema)
+		useRef func(string)
+	)
+	useRef = func(name string) {
+		name = strings.TrimPrefix(name, "#/definitions/")
+		if name == "" || used[name] {
+			return
+		}
+		used[name] = true
+		if ref, ok := prog.References[name]; ok {
+			walk(ref.Schema)
+		}
+	}
+	walk = func(s *Schema) {
+		if s == nil {
+			return
+		}
+		useRef(s.Reference)
+		walk(s.Items)
+		walk(s.AdditionalProperties)
+		for _, p := range s.Properties {
+			walk(p)
+		}
+	}
+	use := func(r *Ref) {
+		if r != nil {
+			useRef(r.Reference)
+		}
+	}
+
+	for _, e := range prog.Endpoints {
+		for _, r := range []*Ref{e.Request.Body, e.Request.Path, e.Request.Query,
+			e.Request.Form, e.Request.Header, e.Request.Cookie} {
+			use(r)
+		}
+		for code, resp := range e.Responses {
+			use(resp.Body)
+			use(resp.Headers)
+			if resp.Body == nil || resp.Body.Reference == "" {
+				// {default}
+				if dr, ok := prog.Config.DefaultResponse[code]; ok {
+					use(dr.Body)
+				}
+			}
+		}
+	}
+
+	for k := range prog.References {
+		if !used[k] {
+			delete(prog.References, k)
+		}
+	}
+	return nil
+}
+
+// RewritePrefix replaces the path prefix from with to for all endpoints with
+// tag, or all endpoints if tag is empty. Endpoints whose path doesn't start
+// with from are left alone; an empty from adds to to every path.
+func RewritePrefix(tag, from, to string) func(*Program) error {
+	return func(prog *Program) error {
+		for _, e := range prog.Endpoints {
+			if e.tagged(tag) && strings.HasPrefix(e.Path, from) {
+				e.Path = to + strings.TrimPrefix(e.Path, from)
+			}
+		}
+		return nil
+	}
+}
+
+// DefaultTag sets the tags of all endpoints without tags to tag.
+func DefaultTag(tag string) func(*Program) error {
+	return func(prog *Program) error {
+		for _, e := range prog.Endpoints {
+			if len(e.Tags) == 0 {
+				e.Tags = []string{tag}
+			}
+		}
+		return nil
+	}
+}
+
+// DefaultResponses adds the Config.DefaultResponse for codes to all endpoints
+// which don't document a response with that code; all default responses are
+// added if codes is empty.
+func DefaultResponses(codes ...int) func(*Program) error {
+	return func(prog *Program) error {
+		add := codes
+		if len(add) == 0 {
+			for code := range prog.Config.DefaultResponse {
+				add = append(add, code)
+			}
+		}
+
+		for _, e := range prog.Endpoints {
+			for _, code := range add {
+				dr, ok := prog.Config.DefaultResponse[code]
+				if !ok {
+					continue
+				}
+				if _, ok := e.Responses[code]; ok {
+					continue
+				}
+				if e.Responses == nil {
+					e.Responses = make(map[int]Response)
+				}
+				if dr.Body != nil {
+					body := *dr.Body
+					dr.Body = &body
+				}
+				e.Responses[code] = dr
+			}
+		}
+		return nil
+	}
+}
+
+// StripOmitDoc removes all properties marked with {omitdoc} from the schemas
+// in prog.References, so that output functions don't need to handle it.
+//
+// Path parameters are always required, so these are kept with the
+// description removed.
+func StripOmitDoc(prog *Program) error {
+	removeProperties(prog,
+		func(p *Schema) bool { return p.OmitDoc },
+		func(p *Schema) { p.OmitDoc, p.Description = false, "" })
+	return nil
+}
+
+// removeProperties removes all properties for which rm returns true from the
+// schemas and fields in prog.References.
+//
+// Path parameters are never removed; path is called for them instead, if it's
+// not nil.
+func removeProperties(prog *Program, rm func(*Schema) bool, path func(*Schema)) {
+	for k, ref := range prog.References {
+		if ref.Schema == nil {
+			continue
+		}
+
+		var keep func(*Schema)
+		if ref.Context == ctxPath {
+			keep = func(p *Schema) {
+				if path != nil {
+					path(p)
+				}
+			}
+		}
+		removed := removeProps(structSchema(ref), rm, keep)
+		if len(removed) == 0 {
+			continue
+		}
+
+		// Output functions list parameters from the fields, so remove them
+		// there as well.
+		tagName := prog.Config.StructTag
+		if isParamContext(ref.Context) {
+			tagName = ref.Context
+		}
+		fields := make([]Param, 0, len(ref.Fields))
+		for _, f := range ref.Fields {
+			name := goutil.TagName(f.KindField, tagName)
+			if name == "" {
+				name = f.Name
+			}
+			if !sliceutil.Contains(removed, name) {
+				fields = append(fields, f)
+			}
+		}
+		ref.Fields = fields
+		prog.References[k] = ref
+	}
+}
+
+// structSchema gets the schema for the struct of ref, without the wrapper or
+// slice.
+func structSchema(ref Reference) *Schema {
+	s := ref.Schema
+	if ref.Wrapper != "" && s != nil {
+		s = s.Properties[ref.Wrapper]
+	}
+	if ref.IsSlice && s != nil {
+		s = s.Items
+	}
+	return s
+}
+
+func isParamContext(ctx string) bool {
+	switch ctx {
+	case ctxPath, ctxQuery, ctxForm, ctxHeader, ctxCookie:
+		return true
+	}
+	return false
+}
+
+// removeProps removes all properties for which rm returns true from s and
+// nested schemas, and returns the names of the properties removed from s.
+// Properties of s are passed to keep instead if it's not nil.
+func removeProps(s *Schema, rm func(*Schema) bool, keep func(*Schema)) []string {
+	if s == nil {
+		return nil
+	}
+
+	var removed []string
+	for name, p := range s.Properties {
+		if rm(p) {
+			if keep != nil {
+				keep(p)
+				continue
+			}
+			delete(s.Properties, name)
+			s.Required = sliceutil.Remove(s.Required, name)
+			removed = append(removed, name)
+			continue
+		}
+		removeProps(p, rm, nil)
+	}
+	removeProps(s.Items, rm, nil)
+	removeProps(s.AdditionalProperties, rm, nil)
+	return removed
+}
@@ -0,0 +1,172 @@
+package docparse
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/teamwork/test/diff"
+)
+
+func TestTransform(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.DefaultResponse = map[int]Response{
+		400: {ContentType: "application/json", Body: &Ref{Description: "400 Bad Request", Reference: "errors.Error"}},
+		404: {ContentType: "application/json", Body: &Ref{Description: "404 Not Found", Reference: "errors.Error"}},
+	}
+	prog.Endpoints = []*Endpoint{
+		{Method: "GET", Path: "/v1/admin/users", Tags: []string{"admin"}},
+		{Method: "GET", Path: "/v1/objects", Tags: []string{"objects"},
+			Responses: map[int]Response{200: {}, 404: {Body: &Ref{Description: "custom"}}}},
+		{Method: "GET", Path: "/v1/internal/objects", Tags: []string{"objects", "internal"}},
+		{Method: "POST", Path: "/v1/other"},
+	}
+	prog.Config.Transforms = []func(*Program) error{
+		DropTags("internal"),
+		RewritePrefix("admin", "/v1/admin", "/admin/v1"),
+		DefaultTag("default"),
+		DefaultResponses(404),
+	}
+
+	if err := Transform(prog); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, e := range prog.Endpoints {
+		var codes []string
+		for code, r := range e.Responses {
+			codes = append(codes, fmt.Sprintf("%d %s", code, r.Body))
+		}
+		sort.Strings(codes)
+		out = append(out, fmt.Sprintf("%s %s %v %v", e.Method, e.Path, e.Tags, codes))
+	}
+	want := []string{
+		"GET /admin/v1/users [admin] [404 &{404 Not Found errors.Error}]",
+		"GET /v1/objects [objects] [200 %!s(*docparse.Ref=<nil>) 404 &{custom }]",
+		"POST /v1/other [default] [404 &{404 Not Found errors.Error}]",
+	}
+	if d := diff.Diff(want, out); d != "" {
+		t.Errorf("\n%s", d)
+	}
+
+	// Added responses shouldn't share the Ref.
+	prog.Endpoints[0].Responses[404].Body.Description = "changed"
+	if prog.Endpoints[2].Responses[404].Body.Description != "404 Not Found" {
+		t.Error("body is shared between endpoints")
+	}
+}
+
+func TestStripOmitDoc(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.StructTag = "json"
+	prog.Config.Packages = []string{"./testdata/src/omitdoc"}
+	prog.Config.Transforms = []func(*Program) error{StripOmitDoc}
+	prog.Config.Output = func(_ io.Writer, prog *Program) error { return nil }
+	if err := FindComments(io.Discard, prog); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, k := range sortedRefs(prog) {
+		ref := prog.References[k]
+		var fields []string
+		for _, f := range ref.Fields {
+			fields = append(fields, f.Name)
+		}
+		// Properties are removed from the struct in the wrapper or slice.
+		s := structSchema(ref)
+		var props []string
+		for name, p := range s.Properties {
+			props = append(props, fmt.Sprintf("%s %q", name, p.Description))
+		}
+		sort.Strings(props)
+		out = append(out, fmt.Sprintf("%s %v %v %v", k, fields, props, s.Required))
+	}
+	want := []string{
+		`omitdoc.listed [Name] [name ""] []`,
+		`omitdoc.pathParams [ID Secret] [id "The ID." secret ""] []`,
+		`omitdoc.queryParams [Page] [page "Page number."] []`,
+		`omitdoc.resp [ID Nested] [id "The ID." nested ""] [id]`,
+		`omitdoc.respNested [Name] [name ""] []`,
+		`omitdoc.wrapped [Name] [name ""] []`,
+	}
+	if d := diff.Diff(want, out); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func sortedRefs(prog *Program) []string {
+	keys := make([]string, 0, len(prog.References))
+	for k := range prog.References {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestFilterAudience(t *testing.T) {
+	tests := map[string][]string{
+		"public": {
+			`GET /objects/{id}`,
+			`audience.object [ID Tags] [id tags] [id]`,
+			`audience.pathParams [ID] [id] []`,
+			`audience.queryParams [Page] [page] []`,
+			`audience.tag [Name] [name] []`,
+		},
+		"partner": {
+			`GET /objects/{id}`,
+			`GET /partner/objects`,
+			`audience.object [ID Tags] [id tags] [id]`,
+			`audience.pathParams [ID] [id] []`,
+			`audience.queryParams [Page] [page] []`,
+			`audience.tag [Name] [name] []`,
+		},
+		"internal": {
+			`GET /objects/{id}`,
+			`GET /partner/objects`,
+			`GET /stats`,
+			`audience.object [ID Owner Tags] [id owner tags] [id owner]`,
+			`audience.owner [Name] [name] []`,
+			`audience.pathParams [ID] [id] []`,
+			`audience.queryParams [Page Debug] [debug page] []`,
+			`audience.stats [Count] [count] []`,
+			`audience.tag [Name Score] [name score] []`,
+		},
+	}
+
+	for audience, want := range tests {
+		t.Run(audience, func(t *testing.T) {
+			prog := NewProgram(false)
+			prog.Config.StructTag = "json"
+			prog.Config.Packages = []string{"./testdata/src/audience"}
+			prog.Config.Audience = audience
+			prog.Config.Output = func(_ io.Writer, prog *Program) error { return nil }
+			if err := FindComments(io.Discard, prog); err != nil {
+				t.Fatal(err)
+			}
+
+			var out []string
+			for _, e := range prog.Endpoints {
+				out = append(out, e.Method+" "+e.Path)
+			}
+			for _, k := range sortedRefs(prog) {
+				ref := prog.References[k]
+				var fields, props []string
+				for _, f := range ref.Fields {
+					fields = append(fields, f.Name)
+				}
+				s := structSchema(ref)
+				for name := range s.Properties {
+					props = append(props, name)
+				}
+				sort.Strings(props)
+				out = append(out, fmt.Sprintf("%s %v %v %v", k, fields, props, s.Required))
+			}
+			if d := diff.Diff(want, out); d != "" {
+				t.Errorf("\n%s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package docparse
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WatchFiles gets all files read while parsing: the Go files of the packages
+// and the packages they reference (including files which couldn't be parsed),
+// and all schema, Extend, and example files.
+func (l *Loader) WatchFiles() []string {
+	var files []string
+	for _, m := range []*sync.Map{&l.files, &l.schemas, &l.broken} {
+		m.Range(func(k, _ interface{}) bool {
+			files = append(files, k.(string))
+			return true
+		})
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Invalidate removes the given files from the cache, so they're read again on
+// the next Parse.
+//
+// Adding or removing a file changes the entire package, so the package of
+// every file is removed as well; a directory removes the package in that
+// directory.
+func (l *Loader) Invalidate(paths ...string) {
+	dirs := make(map[string]bool)
+	for _, p := range paths {
+		l.files.Delete(p)
+		l.broken.Delete(p)
+		l.imports.Delete(p)
+		if st, err := os.Stat(p); err == nil && st.IsDir() {
+			dirs[p] = true
+		} else {
+			dirs[filepath.Dir(p)] = true
+		}
+	}
+
+	l.pkgs.Range(func(k, v interface{}) bool {
+		// Also retry packages which couldn't be resolved, as they may exist now.
+		if r := v.(pkgResult); r.pkg == nil || dirs[r.pkg.Dir] {
+			l.pkgs.Delete(k)
+		}
+		return true
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, e := range l.decls {
+		if dirs[e.dir] {
+			delete(l.decls, k)
+		}
+	}
+}
+
+// Watch calls fn every time one of the WatchFiles changes, or a file is added
+// to or removed from their directories; the changed files are invalidated
+// before fn is called. It checks for changes every interval.
+//
+// This never returns.
+func (l *Loader) Watch(interval time.Duration, fn func(changed []string)) {
+	prev := statFiles(l.watchPaths(nil))
+	for {
+		time.Sleep(interval)
+
+		cur := statFiles(l.watchPaths(prev))
+		changed := changedFiles(prev, cur)
+		prev = cur
+		if len(changed) == 0 {
+			continue
+		}
+
+		l.Invalidate(changed...)
+		fn(changed)
+		// fn may have read new files.
+		prev = statFiles(l.watchPaths(prev))
+	}
+}
+
+// watchPaths gets the WatchFiles and their directories, and all paths in prev.
+//
+// Paths which were watched before are kept, as a file isn't read again if its
+// package can't be loaded (e.g. with an error in the package clause) until the
+// error is fixed.
+func (l *Loader) watchPaths(prev map[string]fileStat) []string {
+	files := l.WatchFiles()
+	seen := make(map[string]bool)
+	for _, f := range files {
+		seen[f] = true
+	}
+	for _, f := range files {
+		if d := filepath.Dir(f); !seen[d] {
+			seen[d] = true
+			files = append(files, d)
+		}
+	}
+	for p := range prev {
+		if !seen[p] {
+			seen[p] = true
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+type fileStat struct {
+	mtime time.Time
+	size  int64
+}
+
+// statFiles gets the modification time and size for all paths; files which
+// don't exist are included with the zero value.
+func statFiles(paths []string) map[string]fileStat {
+	m := make(map[string]fileStat, len(paths))
+	for _, p := range paths {
+		var s fileStat
+		if st, err := os.Stat(p); err == nil {
+			s = fileStat{mtime: st.ModTime(), size: st.Size()}
+		}
+		m[p] = s
+	}
+	return m
+}
+
+// changedFiles gets all paths in cur which are different from prev.
+func changedFiles(prev, cur map[string]fileStat) []string {
+	var changed []string
+	for p, s := range cur {
+		if old, ok := prev[p]; ok && old != s {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
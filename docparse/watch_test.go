@@ -0,0 +1,94 @@
+package docparse
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+func TestInvalidate(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.Packages = []string{"./testdata/src/lint"}
+	prog.Config.StructTag = "json"
+	_ = Parse(prog)
+
+	file, err := filepath.Abs("testdata/src/lint/lint.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := prog.Loader
+	if !sliceutil.Contains(l.WatchFiles(), file) {
+		t.Fatalf("%s not in WatchFiles()", file)
+	}
+
+	if _, err := l.resolveImport(file, "lint"); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Invalidate(file)
+	if _, ok := l.files.Load(file); ok {
+		t.Error("still in files cache")
+	}
+	if _, ok := l.imports.Load(file); ok {
+		t.Error("still in imports cache")
+	}
+	for k, e := range l.decls {
+		if e.dir == filepath.Dir(file) {
+			t.Errorf("still in decls cache: %q", k)
+		}
+	}
+	l.pkgs.Range(func(k, v interface{}) bool {
+		if r := v.(pkgResult); r.pkg != nil && r.pkg.Dir == filepath.Dir(file) {
+			t.Errorf("still in package cache: %q", k)
+		}
+		return true
+	})
+}
+
+func TestChangedFiles(t *testing.T) {
+	now := time.Now()
+	prev := map[string]fileStat{
+		"same":    {now, 1},
+		"mtime":   {now, 1},
+		"size":    {now, 1},
+		"removed": {now, 1},
+		"created": {},
+	}
+	cur := map[string]fileStat{
+		"same":    {now, 1},
+		"mtime":   {now.Add(time.Second), 1},
+		"size":    {now, 2},
+		"removed": {},
+		"created": {now, 1},
+		"new":     {now, 1},
+	}
+
+	want := []string{"created", "mtime", "removed", "size"}
+	if out := changedFiles(prev, cur); !reflect.DeepEqual(out, want) {
+		t.Errorf("\nout:  %v\nwant: %v", out, want)
+	}
+}
+
+func TestWatchFilesBroken(t *testing.T) {
+	prog := NewProgram(false)
+	prog.Config.Packages = []string{"./testdata/src/broken"}
+	if err := Parse(prog); err == nil {
+		t.Fatal("no error")
+	}
+
+	file, err := filepath.Abs("testdata/src/broken/broken.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sliceutil.Contains(prog.Loader.WatchFiles(), file) {
+		t.Fatalf("%s not in WatchFiles()", file)
+	}
+
+	prog.Loader.Invalidate(file)
+	if sliceutil.Contains(prog.Loader.WatchFiles(), file) {
+		t.Fatalf("%s still in WatchFiles()", file)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/teamwork/kommentaar
 go 1.26
 
 require (
+	github.com/Strum355/go-difflib v1.1.0
 	github.com/imdario/mergo v0.3.13
 	github.com/teamwork/test v0.0.0-20200108114543-02621bae84ad
 	github.com/teamwork/utils/v2 v2.2.5
@@ -13,7 +14,6 @@ require (
 )
 
 require (
-	github.com/Strum355/go-difflib v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/teamwork/utils v1.0.1-0.20230426101410-71bb0b003654 // indirect
 	golang.org/x/mod v0.34.0 // indirect
@@ -2,6 +2,7 @@
 package html // import "github.com/teamwork/kommentaar/html"
 
 import (
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"os"
 
 	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/utils/v2/goutil"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,6 +25,17 @@ var funcMap = template.FuncMap{
 		}
 		return string(d)
 	},
+
+	"json": func(in interface{}) string {
+		d, err := json.MarshalIndent(in, "", "  ")
+		if err != nil {
+			return fmt.Sprintf("json.Marshal error: %v", err)
+		}
+		return string(d)
+	},
+
+	// Replaced in execute(), as it needs the Program.
+	"params": func(*docparse.Ref, string) ([]param, error) { return nil, nil },
 }
 
 var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
@@ -129,6 +142,21 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 			min-width: 4rem;
 		}
 
+		.example {
+			background-color: #f7f7f7;
+			padding: .5em;
+			line-height: 1.4em;
+		}
+
+		.deprecated .resource {
+			text-decoration: line-through;
+			color: #777;
+		}
+
+		.deprecated-reason {
+			color: #a00;
+		}
+
 		.param-name {
 			display: inline-block;
 			min-width: 11rem;
@@ -154,8 +182,11 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 
 	{{define "paramsTpl"}}
 		<ul>
-			{{range $p := .Params}}
-				<li><code class="param-name">{{$p.Name}}</code> {{$p.Info}}</li>
+			{{range $p := .}}
+				<li><code class="param-name">{{$p.Name}}</code>
+					{{$p.Info}}
+					<sup>({{$p.Type}}{{if $p.Required}}, required{{end}}{{if $p.Deprecated}}, deprecated{{end}})</sup>
+					{{if $p.Example}}e.g. <code>{{$p.Example|json}}</code>{{end}}</li>
 			{{end}}
 		</ul>
 	{{end}}
@@ -176,7 +207,7 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 			</h3>
 		{{end}}
 
-		<div class="endpoint" id="{{$e.Method}}-{{$e.Path}}">
+		<div class="endpoint{{if $e.Deprecated}} deprecated{{end}}" id="{{$e.Method}}-{{$e.Path}}">
 			<div class="endpoint-top">
 				<code class="resource"><span class="method">{{$e.Method}}</span> {{$e.Path}}</code>
 				{{$e.Tagline}}
@@ -184,21 +215,34 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 			</div>
 
 			<div class="endpoint-info">
+				{{if $e.Deprecated}}
+					<p class="deprecated-reason"><strong>Deprecated</strong>{{if $e.DeprecatedReason}}: {{$e.DeprecatedReason}}{{end}}</p>
+				{{end}}
 				<p>{{$e.Info}}</p>
 
 				{{if $e.Request.Path}}
 					<h4>Path parameters</h4>
-					{{/* {{template "paramsTpl" $e.Request.Path}} */}}
+					{{template "paramsTpl" (params $e.Request.Path "path")}}
 				{{end}}
 
 				{{if $e.Request.Query}}
 					<h4>Query parameters</h4>
-					{{/* {{template "paramsTpl" $e.Request.Query}} */}}
+					{{template "paramsTpl" (params $e.Request.Query "query")}}
 				{{end}}
 
 				{{if $e.Request.Form}}
 					<h4>Form parameters</h4>
-					{{/* {{template "paramsTpl" $e.Request.Form}} */}}
+					{{template "paramsTpl" (params $e.Request.Form "form")}}
+				{{end}}
+
+				{{if $e.Request.Header}}
+					<h4>Header parameters</h4>
+					{{template "paramsTpl" (params $e.Request.Header "header")}}
+				{{end}}
+
+				{{if $e.Request.Cookie}}
+					<h4>Cookies</h4>
+					{{template "paramsTpl" (params $e.Request.Cookie "cookie")}}
 				{{end}}
 
 				{{if $e.Request.Body}}
@@ -207,6 +251,10 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 						<li><a href="#{{$e.Request.Body.Reference}}">{{$e.Request.Body.Reference}}</a>
 							<sup>({{$e.Request.ContentType}})</sup></li>
 					</ul>
+					{{if $e.Request.Example}}
+						<h4>Request body example</h4>
+						<pre class="example">{{$e.Request.Example|json}}</pre>
+					{{end}}
 				{{end}}
 
 				<h4>Responses</h4>
@@ -221,6 +269,12 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 								{{end}}
 								<sup>({{$r.ContentType}})</sup>
 							{{end}}
+							{{if $r.Example}}
+								<pre class="example">{{$r.Example|json}}</pre>
+							{{end}}
+							{{if $r.Headers}}
+								{{template "paramsTpl" (params $r.Headers "header")}}
+							{{end}}
 						</li>
 					{{end}}
 				</ul>
@@ -284,33 +338,121 @@ var mainTpl = template.Must(template.New("mainTpl").Funcs(funcMap).Parse(`
 </html>
 `))
 
+func init() {
+	docparse.RegisterOutput("html", "HTML documentation", WriteHTML)
+}
+
 // WriteHTML writes w as HTML.
 func WriteHTML(w io.Writer, prog *docparse.Program) error {
-	// Too hard to write template otherwise.
-	for i := range prog.Endpoints {
-		prog.Endpoints[i].Path = prog.Config.Basepath + prog.Config.Prefix + prog.Endpoints[i].Path
+	prog, err := prepare(prog, "default")
+	if err != nil {
+		return err
+	}
+	return execute(w, prog)
+}
+
+// prepare a copy of prog for the template: HTML has no basepath, so add it to
+// every path, and group untagged endpoints under tag. The endpoints of prog
+// aren't modified.
+func prepare(prog *docparse.Program, tag string) (*docparse.Program, error) {
+	cp := *prog
+	cp.Endpoints = make([]*docparse.Endpoint, 0, len(prog.Endpoints))
+	for _, e := range prog.Endpoints {
+		e := *e
+		cp.Endpoints = append(cp.Endpoints, &e)
+	}
 
-		if len(prog.Endpoints[i].Tags) == 0 {
-			prog.Endpoints[i].Tags = []string{"default"}
+	if cp.Config.Basepath != "" {
+		if err := docparse.RewritePrefix("", "", cp.Config.Basepath)(&cp); err != nil {
+			return nil, err
 		}
 	}
+	return &cp, docparse.DefaultTag(tag)(&cp)
+}
+
+// param is a single path, query, form, header, or cookie parameter.
+type param struct {
+	Name       string
+	Type       string
+	Info       string
+	Required   bool
+	Deprecated bool
+	Example    interface{}
+}
 
-	return mainTpl.Execute(w, prog)
+// execute mainTpl with the "params" template function bound to prog.
+func execute(w io.Writer, prog *docparse.Program) error {
+	tpl, err := mainTpl.Clone()
+	if err != nil {
+		return err
+	}
+	tpl.Funcs(template.FuncMap{
+		"params": func(r *docparse.Ref, in string) ([]param, error) {
+			return params(prog, r, in)
+		},
+	})
+	return tpl.Execute(w, prog)
+}
+
+// params gets the list of parameters for the struct referenced by r.
+func params(prog *docparse.Program, r *docparse.Ref, in string) ([]param, error) {
+	ref, ok := prog.References[r.Reference]
+	if !ok || ref.Schema == nil {
+		return nil, fmt.Errorf("no schema for %q", r.Reference)
+	}
+
+	var out []param
+	for _, f := range ref.Fields {
+		name := goutil.TagName(f.KindField, in)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := ref.Schema.Properties[name]
+		if schema == nil {
+			return nil, fmt.Errorf("schema is nil for %s field %q in %q",
+				in, name, r.Reference)
+		}
+		if schema.OmitDoc && in != "path" {
+			continue
+		}
+
+		p := param{
+			Name:       name,
+			Type:       schema.Type,
+			Info:       schema.Description,
+			Required:   in == "path" || len(schema.Required) > 0,
+			Example:    schema.Example,
+			Deprecated: schema.Deprecated,
+		}
+		if p.Type == "" {
+			p.Type = schema.Reference
+		}
+		out = append(out, p)
+	}
+	return out, nil
 }
 
 // ServeHTML serves HTML documentation at addr.
 func ServeHTML(addr string) func(io.Writer, *docparse.Program) error {
 	return func(_ io.Writer, prog *docparse.Program) error {
-		http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
-			// Rescan, but first clear some fields so we don't end up with
-			// duplicate data.
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			// Rescan with a copy, so that concurrent requests don't share data
+			// and changed files are read again.
+			prog := &docparse.Program{
+				Loader:     docparse.NewLoader(),
+				Config:     prog.Config,
+				References: make(map[string]docparse.Reference),
+			}
+			prog.Loader.Debug = prog.Config.Debug
 			prog.Config.Output = func(io.Writer, *docparse.Program) error {
 				return nil
 			}
-			prog.Endpoints = nil
-			prog.References = make(map[string]docparse.Reference)
 
-			err := docparse.FindComments(os.Stdout, prog)
+			err := docparse.FindCommentsContext(r.Context(), os.Stdout, prog)
 			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				_, wErr := fmt.Fprintf(w, "could not parse comments: %v", err)
@@ -321,13 +463,10 @@ func ServeHTML(addr string) func(io.Writer, *docparse.Program) error {
 				return
 			}
 
-			for _, e := range prog.Endpoints {
-				if len(e.Tags) == 0 {
-					e.Tags = []string{"untagged"}
-				}
+			prog, err = prepare(prog, "untagged")
+			if err == nil {
+				err = execute(w, prog)
 			}
-
-			err = mainTpl.Execute(w, prog)
 			if err != nil {
 				_, wErr := fmt.Fprintf(w, "could not execute template: %v", err)
 				if wErr != nil {
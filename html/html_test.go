@@ -2,6 +2,7 @@ package html
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/teamwork/kommentaar/docparse"
@@ -22,3 +23,22 @@ func TestHTML(t *testing.T) {
 		t.Errorf("short output?")
 	}
 }
+
+func TestHTMLCopy(t *testing.T) {
+	prog := docparse.NewProgram(false)
+	prog.Config.Basepath = "/v1"
+	prog.Endpoints = []*docparse.Endpoint{{Method: "GET", Path: "/objects"}}
+
+	w := bytes.NewBufferString("")
+	if err := WriteHTML(w, prog); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), "/v1/objects") {
+		t.Errorf("no basepath in output")
+	}
+
+	e := prog.Endpoints[0]
+	if e.Path != "/objects" || len(e.Tags) != 0 {
+		t.Errorf("endpoint was modified: %q %v", e.Path, e.Tags)
+	}
+}
@@ -0,0 +1,205 @@
+// Package jsonschema outputs to JSON Schema.
+//
+// The docparse.Schema is modelled on the subset of JSON Schema that Swagger 2
+// supports; this package converts it to a richer model that can express JSON
+// Schema 2020-12 (as used by OpenAPI 3.1) as well as the OpenAPI 3.0 dialect.
+//
+// https://json-schema.org/draft/2020-12/json-schema-core
+// https://json-schema.org/draft/2020-12/json-schema-validation
+package jsonschema // import "github.com/teamwork/kommentaar/jsonschema"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/teamwork/kommentaar/docparse"
+)
+
+// Draft is the identifier for JSON Schema 2020-12.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Dialect to convert to.
+type Dialect int
+
+// Dialects we can convert to.
+const (
+	// OpenAPI30 is the OpenAPI 3.0 schema object, which is an extended subset
+	// of JSON Schema draft 5.
+	OpenAPI30 Dialect = iota
+
+	// Draft202012 is JSON Schema 2020-12, which is also used by OpenAPI 3.1.
+	Draft202012
+)
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Schema      string             `json:"$schema,omitempty" yaml:"$schema,omitempty"`
+	Reference   string             `json:"$ref,omitempty" yaml:"$ref,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty" yaml:"$defs,omitempty"`
+	Title       string             `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// Type is either a single type as a string, or a list of types as a
+	// []string (2020-12 only).
+	Type       interface{}   `json:"type,omitempty" yaml:"type,omitempty"`
+	Nullable   bool          `json:"nullable,omitempty" yaml:"nullable,omitempty"` // OpenAPI 3.0 only.
+	Enum       []interface{} `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Const      interface{}   `json:"const,omitempty" yaml:"const,omitempty"` // 2020-12 only.
+	Format     string        `json:"format,omitempty" yaml:"format,omitempty"`
+	Required   []string      `json:"required,omitempty" yaml:"required,omitempty"`
+	Default    interface{}   `json:"default,omitempty" yaml:"default,omitempty"`
+	Minimum    int           `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+	Maximum    int           `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	ReadOnly   bool          `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	Deprecated bool          `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Example    interface{}   `json:"example,omitempty" yaml:"example,omitempty"`   // OpenAPI 3.0 only.
+	Examples   []interface{} `json:"examples,omitempty" yaml:"examples,omitempty"` // 2020-12 only.
+
+	Items                *Schema            `json:"items,omitempty" yaml:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty" yaml:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+}
+
+// Convert a docparse.Schema to the given dialect.
+//
+// All $ref values are passed through ref, which should return the full
+// reference (e.g. "#/$defs/pkg.Foo"). Properties marked with {omitdoc} are
+// removed. The original schema is never modified.
+func Convert(s *docparse.Schema, d Dialect, ref func(string) string) *Schema {
+	if s == nil {
+		return nil
+	}
+
+	out := &Schema{
+		Title:       s.Title,
+		Description: s.Description,
+		Format:      s.Format,
+		Required:    s.Required,
+		Minimum:     s.Minimum,
+		Maximum:     s.Maximum,
+		Items:       Convert(s.Items, d, ref),
+
+		AdditionalProperties: Convert(s.AdditionalProperties, d, ref),
+	}
+	if s.Type != "" {
+		out.Type = s.Type
+	}
+	if s.Readonly != nil {
+		out.ReadOnly = *s.Readonly
+	}
+	if s.Default != "" {
+		out.Default = TypedValue(s.Type, s.Default)
+	}
+	for _, e := range s.Enum {
+		out.Enum = append(out.Enum, TypedValue(s.Type, e))
+	}
+	if s.Properties != nil {
+		out.Properties = make(map[string]*Schema, len(s.Properties))
+		for k, p := range s.Properties {
+			if p.OmitDoc {
+				continue
+			}
+			out.Properties[k] = Convert(p, d, ref)
+		}
+	}
+
+	if s.Reference != "" {
+		out.Reference = ref(s.Reference)
+	}
+
+	switch d {
+	case OpenAPI30:
+		// $ref can't have siblings in 3.0, so there is no way to make a
+		// reference nullable.
+		if s.Nullable && out.Reference == "" {
+			out.Nullable = true
+			// The enum also applies to null, so it needs to be listed.
+			if len(out.Enum) > 0 {
+				out.Enum = append(out.Enum, nil)
+			}
+		}
+		if s.Deprecated && out.Reference == "" {
+			out.Deprecated = true
+		}
+		out.Example = s.Example
+
+	case Draft202012:
+		switch {
+		case s.Nullable && len(out.Enum) > 0:
+			// The enum also applies to null, so it needs to be listed; this
+			// also means it can't be a const.
+			out.Enum = append(out.Enum, nil)
+		case len(out.Enum) == 1:
+			out.Const, out.Enum = out.Enum[0], nil
+		}
+		if s.Example != nil {
+			out.Examples = []interface{}{s.Example}
+		}
+		out.Deprecated = s.Deprecated
+
+		if s.Nullable {
+			switch {
+			case out.Reference != "":
+				out.AnyOf = []*Schema{{Reference: out.Reference}, {Type: "null"}}
+				out.Reference = ""
+			case s.Type != "":
+				out.Type = []string{s.Type, "null"}
+			}
+		}
+	}
+
+	return out
+}
+
+// TypedValue converts the string v to the JSON type t, falling back to the
+// string if that's not possible; default and enum values are always strings in
+// docparse.Schema.
+func TypedValue(t, v string) interface{} {
+	switch t {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+func init() {
+	docparse.RegisterOutput("jsonschema", "JSON Schema 2020-12 of all types", WriteJSON)
+}
+
+// WriteJSON writes all references in prog to w as a single JSON Schema 2020-12
+// document, which can be given directly to a JSON Schema validator. Every
+// reference is stored in $defs.
+func WriteJSON(w io.Writer, prog *docparse.Program) error {
+	ref := func(s string) string { return "#/$defs/" + s }
+
+	out := Schema{
+		Schema: Draft,
+		Defs:   make(map[string]*Schema, len(prog.References)),
+	}
+	for k, v := range prog.References {
+		if v.Schema == nil {
+			return fmt.Errorf("schema is nil for %q", k)
+		}
+		out.Defs[k] = Convert(v.Schema, Draft202012, ref)
+	}
+
+	d, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(d, '\n'))
+	return err
+}
@@ -0,0 +1,90 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/test/diff"
+)
+
+func TestConvert(t *testing.T) {
+	ref := func(s string) string { return "#/$defs/" + s }
+	tr := true
+
+	tests := []struct {
+		name    string
+		in      *docparse.Schema
+		dialect Dialect
+		want    *Schema
+	}{
+		{"nil", nil, Draft202012, nil},
+		{"basic", &docparse.Schema{Type: "string", Format: "date"}, Draft202012,
+			&Schema{Type: "string", Format: "date"}},
+		{"typed enum", &docparse.Schema{Type: "integer", Enum: []string{"1", "x"}, Default: "1"}, OpenAPI30,
+			&Schema{Type: "integer", Enum: []interface{}{int64(1), "x"}, Default: int64(1)}},
+		{"const", &docparse.Schema{Type: "boolean", Enum: []string{"true"}}, Draft202012,
+			&Schema{Type: "boolean", Const: true}},
+		{"const 3.0", &docparse.Schema{Type: "boolean", Enum: []string{"true"}}, OpenAPI30,
+			&Schema{Type: "boolean", Enum: []interface{}{true}}},
+		{"nullable", &docparse.Schema{Type: "number", Nullable: true}, Draft202012,
+			&Schema{Type: []string{"number", "null"}}},
+		{"nullable 3.0", &docparse.Schema{Type: "number", Nullable: true}, OpenAPI30,
+			&Schema{Type: "number", Nullable: true}},
+		{"nullable enum", &docparse.Schema{Type: "string", Enum: []string{"a"}, Nullable: true}, Draft202012,
+			&Schema{Type: []string{"string", "null"}, Enum: []interface{}{"a", nil}}},
+		{"nullable enum 3.0", &docparse.Schema{Type: "string", Enum: []string{"a", "b"}, Nullable: true}, OpenAPI30,
+			&Schema{Type: "string", Nullable: true, Enum: []interface{}{"a", "b", nil}}},
+		{"nullable ref", &docparse.Schema{Reference: "a.b", Nullable: true}, Draft202012,
+			&Schema{AnyOf: []*Schema{{Reference: "#/$defs/a.b"}, {Type: "null"}}}},
+		{"nullable ref 3.0", &docparse.Schema{Reference: "a.b", Nullable: true}, OpenAPI30,
+			&Schema{Reference: "#/$defs/a.b"}},
+		{"nested", &docparse.Schema{
+			Type:     "object",
+			Readonly: &tr,
+			Properties: map[string]*docparse.Schema{
+				"a": {Type: "array", Items: &docparse.Schema{Reference: "a.b"}},
+				"b": {Type: "string", OmitDoc: true},
+			},
+		}, Draft202012, &Schema{
+			Type:     "object",
+			ReadOnly: true,
+			Properties: map[string]*Schema{
+				"a": {Type: "array", Items: &Schema{Reference: "#/$defs/a.b"}},
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := Convert(tt.in, tt.dialect, ref)
+			if d := diff.Diff(tt.want, out); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	prog := docparse.NewProgram(false)
+	prog.Config.Packages = []string{"../example/..."}
+	prog.Config.Output = WriteJSON
+
+	w := bytes.NewBufferString("")
+	err := docparse.FindComments(w, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out Schema
+	if err := json.Unmarshal(w.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Schema != Draft {
+		t.Errorf("wrong $schema: %q", out.Schema)
+	}
+	if len(out.Defs) < 2 {
+		t.Errorf("len(out.Defs) == %d", len(out.Defs))
+	}
+}
@@ -8,7 +8,11 @@ import (
 
 	"github.com/teamwork/kommentaar/docparse"
 	"github.com/teamwork/kommentaar/html"
+	_ "github.com/teamwork/kommentaar/jsonschema" // Register outputs.
+	_ "github.com/teamwork/kommentaar/markdown"   // Register outputs.
 	"github.com/teamwork/kommentaar/openapi2"
+	_ "github.com/teamwork/kommentaar/openapi3" // Register outputs.
+	_ "github.com/teamwork/kommentaar/postman"  // Register outputs.
 	"github.com/teamwork/utils/v2/goutil"
 	"zgo.at/sconfig"
 	_ "zgo.at/sconfig/handlers/html/template" // template.HTML handler
@@ -47,6 +51,43 @@ func Load(prog *docparse.Program, file string) error {
 			prog.Config.DefaultResponse[code] = *resp
 			return nil
 		},
+
+		"SecurityScheme": func(line []string) error {
+			if prog.Config.SecurityScheme == nil {
+				prog.Config.SecurityScheme = make(map[string]docparse.SecurityScheme)
+			}
+
+			name, s, err := docparse.ParseSecurityScheme(line)
+			if err != nil {
+				return err
+			}
+			if _, ok := prog.Config.SecurityScheme[name]; ok {
+				return fmt.Errorf("security scheme %q defined more than once", name)
+			}
+
+			prog.Config.SecurityScheme[name] = s
+			return nil
+		},
+
+		"CacheDir": func(line []string) error {
+			if len(line) != 1 {
+				return fmt.Errorf("invalid cache-dir: %q", strings.Join(line, " "))
+			}
+
+			prog.Config.CacheDir = line[0]
+			prog.Loader.SetCacheDir(line[0])
+			return nil
+		},
+
+		"DefaultSecurity": func(line []string) error {
+			req, err := docparse.ParseSecurity(prog, strings.Join(line, " "))
+			if err != nil {
+				return err
+			}
+
+			prog.Config.DefaultSecurity = append(prog.Config.DefaultSecurity, req)
+			return nil
+		},
 	})
 	if err != nil {
 		return fmt.Errorf("could not load config: %v", err)
@@ -71,25 +112,16 @@ func Load(prog *docparse.Program, file string) error {
 	return nil
 }
 
-// Output gets the output function from a string.
+// Output gets the output function registered with docparse.RegisterOutput as
+// out; the HTML output is served on addr if it's not empty.
 func Output(out, addr string) (func(io.Writer, *docparse.Program) error, error) {
-	var outFunc func(io.Writer, *docparse.Program) error
-	switch strings.ToLower(out) {
-	case "openapi2-yaml":
-		outFunc = openapi2.WriteYAML
-	case "openapi2-json":
-		outFunc = openapi2.WriteJSON
-	case "openapi2-jsonindent":
-		outFunc = openapi2.WriteJSONIndent
-	case "html":
-		if addr != "" {
-			outFunc = html.ServeHTML(addr)
-		} else {
-			outFunc = html.WriteHTML
-		}
-	default:
-		return nil, fmt.Errorf("unknown value: %q", out)
+	if strings.EqualFold(out, "html") && addr != "" {
+		return html.ServeHTML(addr), nil
 	}
 
+	outFunc, ok := docparse.LookupOutput(out)
+	if !ok {
+		return nil, fmt.Errorf("unknown value: %q", out)
+	}
 	return outFunc, nil
 }
@@ -1,6 +1,8 @@
 package kconfig
 
 import (
+	"fmt"
+	"io"
 	"testing"
 
 	"github.com/teamwork/kommentaar/docparse"
@@ -17,6 +19,7 @@ func TestLoad(t *testing.T) {
 			default-response 400: github.com/teamwork/kommentaar/docparse.Param
 			default-response 404 (application/json): net/mail.Address
 		`))},
+		{"cache-dir", []byte("cache-dir .cache/kommentaar\n")},
 	}
 
 	for _, tt := range tests {
@@ -33,3 +36,34 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+// Outputs can't be unregistered from here, so every run of
+// TestLoadRegisteredOutput (e.g. with -count=2) uses a new name.
+var testOutputs int
+
+func TestLoadRegisteredOutput(t *testing.T) {
+	testOutputs++
+	called := false
+	docparse.RegisterOutput(fmt.Sprintf("kconfig-test-%d", testOutputs), "Test output", func(io.Writer, *docparse.Program) error {
+		called = true
+		return nil
+	})
+
+	f, clean := test.TempFile(t, fmt.Sprintf("output Kconfig-Test-%d\n", testOutputs))
+	defer clean()
+
+	prog := docparse.NewProgram(false)
+	if err := Load(prog, f); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := prog.Config.Output(nil, prog); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("registered output not used")
+	}
+
+	if _, err := Output("doesnt-exist", ""); !test.ErrorContains(err, `unknown value: "doesnt-exist"`) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
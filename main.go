@@ -2,13 +2,18 @@ package main // import "github.com/teamwork/kommentaar"
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/Strum355/go-difflib/difflib"
 	"github.com/teamwork/kommentaar/docparse"
 	"github.com/teamwork/kommentaar/kconfig"
 	"github.com/teamwork/kommentaar/openapi2"
@@ -16,7 +21,9 @@ import (
 
 func main() {
 	flag.Usage = func() {
-		_, _ = fmt.Fprintf(os.Stderr, "usage: kommentaar [pkg pkg...]\n\n")
+		_, _ = fmt.Fprintf(os.Stderr, "usage: kommentaar [flags] [pkg pkg...]\n"+
+			"       kommentaar [flags] lint [flags] [pkg pkg...]\n"+
+			"       kommentaar diff old.yaml new.yaml\n\n")
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
@@ -31,24 +38,78 @@ func main() {
 	}
 }
 
-var stdout io.Writer = os.Stdout
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
 
 func start() (bool, error) {
 	config := flag.String("config", "", "configuration file")
 	debug := flag.Bool("debug", false, "print debug output to stderr")
 	addr := flag.String("serve", "", "serve HTML output on this address, instead of writing to\n"+
 		"stdout; every page load will rescan the source tree")
-	output := flag.String("output", "", `output function, valid values are:
-	openapi2-yaml        OpenAPI/Swagger 2.0 as YAML
-	openapi2-json        OpenAPI/Swagger 2.0 as JSON
-	openapi2-jsonindent  OpenAPI/Swagger 2.0 as JSON indented
-	html                 HTML documentation
-`)
+	outputs := docparse.Outputs()
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Name < outputs[j].Name })
+	outputHelp := "output function, valid values are:\n"
+	for _, o := range outputs {
+		desc := o.Desc
+		if o.Name == "openapi2-yaml" {
+			desc += " (default)"
+		}
+		outputHelp += fmt.Sprintf("\t%-21s %s\n", o.Name, desc)
+	}
+	output := flag.String("output", "", outputHelp)
 	outFile := flag.String("out", "", "write output to this file instead of stdout")
+	irFile := flag.String("ir", "", "read the endpoints from this file written with -output ir-json,\n"+
+		"instead of parsing packages")
+	check := flag.String("check", "", "compare the output with this file instead of writing it; show a diff\n"+
+		"and exit with an error if the file is out of date")
+	watch := flag.Bool("watch", false, "write the output to -out again every time a Go file of the packages or\n"+
+		"a file they reference changes")
+	routes := flag.Bool("routes", false, "report routes registered with net/http, chi, gorilla/mux, echo, or\n"+
+		"gin without documentation, and documented endpoints which aren't\n"+
+		"registered, instead of writing output")
+	audience := flag.String("audience", "", "only include endpoints, parameters, and properties visible to this\n"+
+		"audience, as set with the Audience directive and {internal}")
+	format := flag.String("format", "text", "format for errors and lint problems: text, json, or sarif;\n"+
+		"text is written to stderr, and json and sarif to stdout (or -out)")
+	cacheFlag := flag.Bool("cache", false, "cache package lists and declarations of referenced packages on\n"+
+		"disk, to speed up the next run; set cache-dir in the config file to\n"+
+		"use a different directory")
 	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to `file`")
 	memprofile := flag.String("memprofile", "", "write memory profile to `file`")
 
-	flag.Parse()
+	// Flags can be given before and after the subcommand.
+	_ = flag.CommandLine.Parse(os.Args[1:])
+	if flag.Arg(0) == "diff" {
+		if flag.NArg() != 3 {
+			return true, errors.New("diff: need two files")
+		}
+		return false, runDiff(stdout, flag.Arg(1), flag.Arg(2))
+	}
+
+	// The lint subcommand only reports problems, instead of writing output.
+	lint := flag.Arg(0) == "lint"
+	if lint {
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	switch *format {
+	case "text", "json", "sarif":
+	default:
+		return true, fmt.Errorf("-format: unknown value: %q", *format)
+	}
+
+	if *check != "" && (*outFile != "" || lint || *routes) {
+		return true, errors.New("-check can't be used with -out, -routes, or lint")
+	}
+	// Diagnostics would replace the output in -out on every error.
+	if *watch && (*outFile == "" || *check != "" || lint || *routes || *format != "text") {
+		return true, errors.New("-watch requires -out, and can't be used with -check, -routes, -format, or lint")
+	}
+	if *irFile != "" && (*watch || lint || *routes) {
+		return true, errors.New("-ir can't be used with -watch, -routes, or lint")
+	}
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -71,6 +132,19 @@ func start() (bool, error) {
 		}
 	}
 
+	if *audience != "" {
+		prog.Config.Audience = *audience
+	}
+
+	if *cacheFlag && prog.Config.CacheDir == "" {
+		var err error
+		prog.Config.CacheDir, err = docparse.DefaultCacheDir()
+		if err != nil {
+			return false, fmt.Errorf("-cache: %w", err)
+		}
+		prog.Loader.SetCacheDir(prog.Config.CacheDir)
+	}
+
 	if prog.Config.Output == nil {
 		prog.Config.Output = openapi2.WriteYAML
 	}
@@ -82,6 +156,11 @@ func start() (bool, error) {
 		}
 	}
 
+	if *routes {
+		prog.Config.Routes = true
+		prog.Config.Output = docparse.WriteRoutes
+	}
+
 	pkgs := flag.Args()
 	if len(pkgs) > 0 {
 		prog.Config.Packages = pkgs
@@ -90,22 +169,59 @@ func start() (bool, error) {
 		prog.Config.Packages = []string{"."}
 	}
 
-	w := stdout
-	var buf *bytes.Buffer
-	if *outFile != "" {
-		buf = &bytes.Buffer{}
-		w = buf
-	}
+	run := func() error {
+		w := stdout
+		var buf *bytes.Buffer
+		if *outFile != "" || *check != "" {
+			buf = &bytes.Buffer{}
+			w = buf
+		}
 
-	err := docparse.FindComments(w, prog)
-	if err != nil {
-		return false, err
-	}
+		var (
+			err   error
+			diags bool // w has diagnostics instead of the output.
+		)
+		if lint {
+			// Text is written to stderr, like the errors from FindComments.
+			if *format == "text" {
+				err = runLint(stderr, prog, *format)
+			} else {
+				err, diags = runLint(w, prog, *format), true
+			}
+		} else if *irFile != "" {
+			err = runIR(w, prog, *irFile)
+		} else {
+			err = docparse.FindComments(w, prog)
+			var d docparse.Diagnostics
+			if errors.As(err, &d) && *format != "text" {
+				if err := writeDiagnostics(w, d, *format); err != nil {
+					return err
+				}
+				err, diags = fmt.Errorf("%d errors occurred", len(d)), true
+			}
+		}
 
-	if *outFile != "" {
-		if err := os.WriteFile(*outFile, buf.Bytes(), 0666); err != nil {
-			return false, fmt.Errorf("writing output file: %w", err)
+		// Also write the file if there are problems which were written to it,
+		// but never replace it with partial output.
+		if *outFile != "" && (err == nil || diags && buf.Len() > 0) {
+			if err := os.WriteFile(*outFile, buf.Bytes(), 0666); err != nil {
+				return fmt.Errorf("writing output file: %w", err)
+			}
+		}
+		if err != nil {
+			return err
 		}
+		if *check != "" {
+			return checkOutput(stdout, *check, buf.Bytes())
+		}
+		return nil
+	}
+
+	if *watch {
+		watchOutput(prog, *outFile, run)
+	}
+	if err := run(); err != nil {
+		return false, err
 	}
 
 	if *memprofile != "" {
@@ -123,3 +239,131 @@ func start() (bool, error) {
 
 	return false, nil
 }
+
+// watchOutput runs run, and then again every time one of the files read by
+// docparse changes.
+//
+// This never returns.
+func watchOutput(prog *docparse.Program, outFile string, run func() error) {
+	report := func() {
+		if err := run(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "kommentaar: %s\n", err)
+			return
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "kommentaar: wrote %s\n", outFile)
+	}
+
+	report()
+	prog.Loader.Watch(500*time.Millisecond, func(changed []string) {
+		_, _ = fmt.Fprintf(os.Stderr, "kommentaar: %d files changed\n", len(changed))
+		prog.Reset()
+		report()
+	})
+}
+
+// runLint writes all problems found by docparse.Lint to w.
+func runLint(w io.Writer, prog *docparse.Program, format string) error {
+	diags, err := docparse.Lint(prog)
+	if err != nil {
+		return err
+	}
+	if err := writeDiagnostics(w, diags, format); err != nil {
+		return err
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("%d problems", len(diags))
+	}
+	return nil
+}
+
+// runIR writes the output for the Program in the ir-json file with the output
+// function and audience of prog.
+func runIR(w io.Writer, prog *docparse.Program, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	loaded, err := docparse.LoadIR(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	loaded.Config.Output = prog.Config.Output
+	loaded.Config.Audience = prog.Config.Audience
+	if err := docparse.Transform(loaded); err != nil {
+		return err
+	}
+	return loaded.Config.Output(w, loaded)
+}
+
+// runDiff writes all changes between the OpenAPI 2 files oldFile and newFile
+// to w, and returns an error if any are breaking.
+func runDiff(w io.Writer, oldFile, newFile string) error {
+	oldSpec, err := openapi2.Read(oldFile)
+	if err != nil {
+		return err
+	}
+	newSpec, err := openapi2.Read(newFile)
+	if err != nil {
+		return err
+	}
+
+	breaking := 0
+	for _, c := range openapi2.Diff(oldSpec, newSpec) {
+		if c.Breaking {
+			breaking++
+		}
+		if _, err := fmt.Fprintln(w, c); err != nil {
+			return err
+		}
+	}
+	if breaking > 0 {
+		return fmt.Errorf("%d breaking changes", breaking)
+	}
+	return nil
+}
+
+// checkOutput compares the generated output with the contents of file, and
+// writes a unified diff to w if it's different.
+func checkOutput(w io.Writer, file string, out []byte) error {
+	want, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("-check: %w", err)
+	}
+	if bytes.Equal(want, out) {
+		return nil
+	}
+
+	lines := func(b []byte) []string {
+		l := strings.SplitAfter(string(b), "\n")
+		if l[len(l)-1] == "" {
+			l = l[:len(l)-1]
+		}
+		return l
+	}
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(want),
+		B:        lines(out),
+		FromFile: file,
+		ToFile:   "generated",
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, d); err != nil {
+		return err
+	}
+	return fmt.Errorf("%s is out of date; regenerate it", file)
+}
+
+// writeDiagnostics writes diags to w as text, json, or sarif.
+func writeDiagnostics(w io.Writer, diags docparse.Diagnostics, format string) error {
+	switch format {
+	case "json":
+		return diags.WriteJSON(w)
+	case "sarif":
+		return diags.WriteSARIF(w)
+	default:
+		return diags.WriteText(w)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"flag"
 	"go/build"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/teamwork/kommentaar/docparse"
 	"github.com/teamwork/kommentaar/kconfig"
 	"github.com/teamwork/kommentaar/openapi2"
+	"github.com/teamwork/kommentaar/openapi3"
 	"github.com/teamwork/test"
 	"github.com/teamwork/test/diff"
 )
@@ -28,15 +30,124 @@ func TestStart(t *testing.T) {
 	}
 }
 
+func TestStartOutFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swagger.yaml")
+	run := func(args ...string) error {
+		t.Helper()
+		if err := os.WriteFile(file, []byte("old"), 0666); err != nil {
+			t.Fatal(err)
+		}
+		os.Args = append([]string{"", "-out", file}, args...)
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		stdout = bytes.NewBufferString("")
+		_, err := start()
+		return err
+	}
+	read := func() string {
+		t.Helper()
+		d, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(d)
+	}
+
+	// Errors are written to stderr, and the file is kept.
+	err := run("./testdata/openapi2/src/path-invalid")
+	if !test.ErrorContains(err, "1 errors occurred") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if out := read(); out != "old" {
+		t.Errorf("file was changed: %q", out)
+	}
+
+	// Unless they're written as JSON.
+	err = run("-format", "json", "./testdata/openapi2/src/path-invalid")
+	if !test.ErrorContains(err, "1 errors occurred") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if out := read(); !strings.Contains(out, `"rule": "parse-error"`) {
+		t.Errorf("no diagnostics in file: %q", out)
+	}
+
+	// Lint problems are written to stderr as text, like errors.
+	var errOut bytes.Buffer
+	stderr = &errOut
+	defer func() { stderr = os.Stderr }()
+	err = run("lint", "./testdata/openapi2/src/path-invalid")
+	if !test.ErrorContains(err, "1 problems") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if !strings.Contains(errOut.String(), "parse-error") {
+		t.Errorf("no diagnostics on stderr: %q", errOut.String())
+	}
+	if out := read(); out != "old" {
+		t.Errorf("file was changed: %q", out)
+	}
+
+	// Flags are also parsed after lint.
+	err = run("lint", "-format", "json", "./testdata/openapi2/src/path-invalid")
+	if !test.ErrorContains(err, "1 problems") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if out := read(); !strings.Contains(out, `"rule": "parse-error"`) {
+		t.Errorf("no diagnostics in file: %q", out)
+	}
+
+	// With -watch this would replace the output on every syntax error.
+	err = run("-watch", "-format", "json", "./testdata/openapi2/src/path-invalid")
+	if !test.ErrorContains(err, "-watch requires -out, and can't be used with") {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestCheckOutput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swagger.yaml")
+	if err := os.WriteFile(file, []byte("a: 1\nb: 2\nc: 3\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := checkOutput(buf, file, []byte("a: 1\nb: 2\nc: 3\n")); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("diff for same output:\n%s", buf)
+	}
+
+	err := checkOutput(buf, file, []byte("a: 1\nb: 3\nc: 3\n"))
+	if !test.ErrorContains(err, "swagger.yaml is out of date") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	want := "--- " + file + "\n+++ generated\n@@ -1,3 +1,3 @@\n a: 1\n-b: 2\n+b: 3\n c: 3\n"
+	if d := diff.TextDiff(want, buf.String()); d != "" {
+		t.Error(d)
+	}
+}
+
 func TestOpenAPI2(t *testing.T) {
-	tests, err := os.ReadDir("./testdata/openapi2/src")
+	testGolden(t, "openapi2", openapi2.WriteYAML, openapi2.WriteJSONIndent)
+}
+
+func TestOpenAPI3(t *testing.T) {
+	testGolden(t, "openapi3", openapi3.WriteYAML, openapi3.WriteJSONIndent)
+}
+
+func TestOpenAPI31(t *testing.T) {
+	testGolden(t, "openapi31", openapi3.WriteYAML31, openapi3.WriteJSONIndent31)
+}
+
+// testGolden runs all the tests in ./testdata/<dir>/src, comparing the output
+// of yaml to want.yaml and (if it exists) the output of json to want.json.
+func testGolden(t *testing.T, dir string, yaml, json func(io.Writer, *docparse.Program) error) {
+	tests, err := os.ReadDir("./testdata/" + dir + "/src")
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.Name(), func(t *testing.T) {
-			path := "./testdata/openapi2/src/" + tt.Name()
+			path := "./testdata/" + dir + "/src/" + tt.Name()
 
 			want, err := os.ReadFile(path + "/want.yaml")
 			if err != nil && !os.IsNotExist(err) {
@@ -57,22 +168,26 @@ func TestOpenAPI2(t *testing.T) {
 			wantErr = bytes.TrimSpace(wantErr)
 
 			wd, _ := os.Getwd()
-			build.Default.GOPATH = filepath.Join(wd, "/testdata/openapi2")
-
-			prog := docparse.NewProgram(false)
-			prog.Config.Title = "x"
-			prog.Config.Version = "x"
-			prog.Config.Packages = []string{"./testdata/openapi2/src/" + tt.Name()}
-			prog.Config.Output = openapi2.WriteYAML
-			prog.Config.StructTag = "json"
-
-			// Allow test to override config
-			testConfig := path + "/test.conf"
-			if _, err := os.Stat(testConfig); err == nil {
-				if err := kconfig.Load(prog, testConfig); err != nil {
-					t.Fatalf("test.conf: %v", err)
+			build.Default.GOPATH = filepath.Join(wd, "/testdata/"+dir)
+
+			newProg := func() *docparse.Program {
+				prog := docparse.NewProgram(false)
+				prog.Config.Title = "x"
+				prog.Config.Version = "x"
+				prog.Config.Packages = []string{path}
+				prog.Config.Output = yaml
+				prog.Config.StructTag = "json"
+
+				// Allow test to override config
+				testConfig := path + "/test.conf"
+				if _, err := os.Stat(testConfig); err == nil {
+					if err := kconfig.Load(prog, testConfig); err != nil {
+						t.Fatalf("test.conf: %v", err)
+					}
 				}
+				return prog
 			}
+			prog := newProg()
 
 			outBuf := bytes.NewBuffer(nil)
 			err = docparse.FindComments(outBuf, prog)
@@ -87,8 +202,33 @@ func TestOpenAPI2(t *testing.T) {
 				t.Fatalf("diff\n%v", d)
 			}
 
+			// Should be the same when written from the intermediate
+			// representation.
+			if len(wantErr) == 0 {
+				prog := newProg()
+				prog.Config.Output = docparse.WriteIR
+				irBuf := bytes.NewBuffer(nil)
+				if err := docparse.FindComments(irBuf, prog); err != nil {
+					t.Fatalf("IR error: %v", err)
+				}
+				loaded, err := docparse.LoadIR(irBuf)
+				if err != nil {
+					t.Fatalf("LoadIR: %v", err)
+				}
+				outBuf := bytes.NewBuffer(nil)
+				if err := yaml(outBuf, loaded); err != nil {
+					t.Fatalf("IR output error: %v", err)
+				}
+				out := strings.TrimSpace(outBuf.String()) + "\n"
+
+				d := diff.TextDiff(string(want), out)
+				if d != "" {
+					t.Fatalf("wrong output from IR\n%v", d)
+				}
+			}
+
 			if len(wantJSON) > 1 {
-				prog.Config.Output = openapi2.WriteJSONIndent
+				prog.Config.Output = json
 				prog.Endpoints = nil
 				prog.References = make(map[string]docparse.Reference)
 				outBuf := bytes.NewBuffer(nil)
@@ -0,0 +1,408 @@
+// Package markdown outputs to Markdown.
+package markdown // import "github.com/teamwork/kommentaar/markdown"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/utils/v2/goutil"
+	"github.com/teamwork/utils/v2/sliceutil"
+)
+
+func init() {
+	docparse.RegisterOutput("markdown", "Markdown documentation", WriteMarkdown)
+}
+
+// WriteMarkdown writes w as Markdown.
+//
+// Every tag is a section with all the endpoints for that tag, so endpoints with
+// more than one tag are listed more than once, followed by a section with all
+// definitions. Parameters and fields are written as tables,
+// and references link to the definitions.
+func WriteMarkdown(w io.Writer, prog *docparse.Program) error {
+	m := &writer{prog: prog}
+
+	title := strings.TrimSpace(prog.Config.Title + " API documentation " + prog.Config.Version)
+	m.printf("# %s\n\n", title)
+	if prog.Config.Description != "" {
+		m.printf("%s\n\n", strings.TrimSpace(string(prog.Config.Description)))
+	}
+	if prog.Config.ContactEmail != "" {
+		name := prog.Config.ContactName
+		if name == "" {
+			name = prog.Config.ContactEmail
+		}
+		m.printf("Contact [%s](mailto:%s) for questions.\n\n", name, prog.Config.ContactEmail)
+	}
+
+	// Endpoints with more than one tag are in the section of every tag.
+	var tags []string
+	byTag := make(map[string][]*docparse.Endpoint)
+	for _, e := range prog.Endpoints {
+		etags := e.Tags
+		if len(etags) == 0 {
+			etags = []string{"default"}
+		}
+		for _, tag := range etags {
+			if _, ok := byTag[tag]; !ok {
+				tags = append(tags, tag)
+			}
+			byTag[tag] = append(byTag[tag], e)
+		}
+	}
+	for _, tag := range tags {
+		m.printf("## %s\n\n", tag)
+		for _, e := range byTag[tag] {
+			if err := m.endpoint(e); err != nil {
+				return fmt.Errorf("%s %s: %v", e.Method, e.Path, err)
+			}
+		}
+	}
+
+	// Parameters are already listed with the endpoints, and embedded structs
+	// are merged in the structs they're embedded in.
+	var defs []string
+	for _, k := range sortedKeys(prog.References) {
+		ref := prog.References[k]
+		switch {
+		case ref.Schema == nil:
+			return fmt.Errorf("schema is nil for %q", k)
+		case ref.IsEmbed:
+		case ref.Context == "path", ref.Context == "query", ref.Context == "form",
+			ref.Context == "header", ref.Context == "cookie":
+		default:
+			defs = append(defs, k)
+		}
+	}
+	if len(defs) > 0 {
+		m.printf("## Definitions\n\n")
+		for _, k := range defs {
+			m.definition(k, prog.References[k])
+		}
+	}
+
+	_, err := w.Write(bytes.TrimRight(m.buf.Bytes(), "\n"))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("\n"))
+	return err
+}
+
+type writer struct {
+	prog *docparse.Program
+	buf  bytes.Buffer
+}
+
+func (m *writer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&m.buf, format, args...)
+}
+
+// row is a single row in a parameter or field table.
+type row struct {
+	name, typ, constraints, info string
+	required                     bool
+}
+
+func (m *writer) table(rows []row) {
+	if len(rows) == 0 {
+		return
+	}
+	m.printf("| Name | Type | Required | Constraints | Description |\n")
+	m.printf("| ---- | ---- | -------- | ----------- | ----------- |\n")
+	for _, r := range rows {
+		req := "no"
+		if r.required {
+			req = "yes"
+		}
+		m.printf("| `%s` | %s | %s | %s | %s |\n", r.name, cell(r.typ), req, cell(r.constraints), cell(r.info))
+	}
+	m.printf("\n")
+}
+
+func (m *writer) endpoint(e *docparse.Endpoint) error {
+	m.printf("### %s %s\n\n", e.Method, m.prog.Config.Basepath+e.Path)
+	if e.Tagline != "" {
+		m.printf("%s\n\n", e.Tagline)
+	}
+	if e.Deprecated {
+		if e.DeprecatedReason != "" {
+			m.printf("**Deprecated**: %s\n\n", e.DeprecatedReason)
+		} else {
+			m.printf("**Deprecated**\n\n")
+		}
+	}
+	if e.Info != "" {
+		m.printf("%s\n\n", e.Info)
+	}
+
+	for _, p := range []struct {
+		title, in string
+		ref       *docparse.Ref
+	}{
+		{"Path parameters", "path", e.Request.Path},
+		{"Query parameters", "query", e.Request.Query},
+		{"Form parameters", "form", e.Request.Form},
+		{"Header parameters", "header", e.Request.Header},
+		{"Cookies", "cookie", e.Request.Cookie},
+	} {
+		if p.ref == nil {
+			continue
+		}
+		rows, err := m.params(p.ref, p.in)
+		if err != nil {
+			return err
+		}
+		m.printf("**%s**\n\n", p.title)
+		m.table(rows)
+	}
+
+	if e.Request.Body != nil {
+		m.printf("**Request body** (%s): %s\n\n", e.Request.ContentType, m.link(e.Request.Body.Reference))
+		if e.Request.Example != nil {
+			m.printf("**Request body example**\n\n")
+			if err := m.example(e.Request.Example); err != nil {
+				return fmt.Errorf("request body example: %v", err)
+			}
+		}
+	}
+
+	m.printf("**Responses**\n\n")
+	codes := sortedKeys(e.Responses)
+	for _, code := range codes {
+		r := e.Responses[code]
+		m.printf("- `%d %s`", code, http.StatusText(code))
+		if r.Body != nil {
+			dr, ok := m.prog.Config.DefaultResponse[code]
+			switch {
+			case r.Body.Reference != "":
+				m.printf(": %s (%s)", m.link(r.Body.Reference), r.ContentType)
+			case r.Keyword == "{default}" && ok && dr.Body != nil:
+				ct := r.ContentType
+				if dr.ContentType != "" {
+					ct = dr.ContentType
+				}
+				m.printf(": %s (%s)", m.link(dr.Body.Reference), ct)
+			case r.Keyword == "{empty}":
+				m.printf(" (no data)")
+			case r.Keyword == "{data}":
+				m.printf(" (%s data)", r.ContentType)
+			}
+		}
+		m.printf("\n")
+	}
+	m.printf("\n")
+
+	for _, code := range codes {
+		r := e.Responses[code]
+		if r.Headers != nil {
+			rows, err := m.params(r.Headers, "header")
+			if err != nil {
+				return err
+			}
+			m.printf("**Response %d headers**\n\n", code)
+			m.table(rows)
+		}
+		if r.Example != nil {
+			m.printf("**Response %d example**\n\n", code)
+			if err := m.example(r.Example); err != nil {
+				return fmt.Errorf("response %d example: %v", code, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// params gets the table rows for the struct referenced by r, in the order
+// they're defined.
+func (m *writer) params(r *docparse.Ref, in string) ([]row, error) {
+	ref, ok := m.prog.References[r.Reference]
+	if !ok || ref.Schema == nil {
+		return nil, fmt.Errorf("no schema for %q", r.Reference)
+	}
+
+	var rows []row
+	for _, f := range ref.Fields {
+		name := goutil.TagName(f.KindField, in)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := ref.Schema.Properties[name]
+		if schema == nil {
+			return nil, fmt.Errorf("schema is nil for %s field %q in %q", in, name, r.Reference)
+		}
+		if schema.OmitDoc && in != "path" {
+			continue
+		}
+
+		r := m.row(name, schema, in == "path" || len(schema.Required) > 0)
+		if schema.OmitDoc {
+			r.info = ""
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+func (m *writer) definition(name string, ref docparse.Reference) {
+	m.printf("<a id=\"%s\"></a>\n\n### %s\n\n", anchor(name), name)
+	if ref.Info != "" {
+		m.printf("%s\n\n", ref.Info)
+	}
+
+	s := ref.Schema
+	if len(s.Properties) == 0 && s.Items == nil && s.AdditionalProperties == nil {
+		m.printf("Type: %s\n\n", m.typ(s))
+		return
+	}
+	if s.Type != "object" || len(s.Properties) == 0 {
+		m.printf("Type: %s\n\n", m.typ(s))
+	}
+
+	var rows []row
+	m.fields(&rows, "", s)
+	m.table(rows)
+}
+
+// fields adds rows for all properties of s, including the properties of
+// objects and arrays of objects which aren't references.
+func (m *writer) fields(rows *[]row, prefix string, s *docparse.Schema) {
+	switch {
+	case s.Reference != "":
+		return
+	case s.Items != nil:
+		m.fields(rows, prefix+"[]", s.Items)
+		return
+	case s.AdditionalProperties != nil && len(s.Properties) == 0:
+		m.fields(rows, prefix+".*", s.AdditionalProperties)
+		return
+	}
+
+	for _, name := range sortedKeys(s.Properties) {
+		p := s.Properties[name]
+		if p.OmitDoc {
+			continue
+		}
+		full := name
+		if prefix != "" {
+			full = prefix + "." + name
+		}
+		*rows = append(*rows, m.row(full, p, sliceutil.Contains(s.Required, name)))
+		m.fields(rows, full, p)
+	}
+}
+
+func (m *writer) row(name string, s *docparse.Schema, required bool) row {
+	r := row{
+		name:        name,
+		typ:         m.typ(s),
+		constraints: constraints(s),
+		info:        s.Description,
+		required:    required,
+	}
+	if s.Deprecated {
+		r.info = strings.TrimSpace("**Deprecated**. " + r.info)
+	}
+	return r
+}
+
+// typ gets a description of the type of s.
+func (m *writer) typ(s *docparse.Schema) string {
+	switch {
+	case s.Reference != "":
+		return m.link(s.Reference)
+	case s.Items != nil:
+		return "array of " + m.typ(s.Items)
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map of " + m.typ(s.AdditionalProperties)
+	}
+
+	t := s.Type
+	if t == "" {
+		t = "any"
+	}
+	if s.Format != "" {
+		t += " (" + s.Format + ")"
+	}
+	return t
+}
+
+func constraints(s *docparse.Schema) string {
+	var c []string
+	if len(s.Enum) > 0 {
+		c = append(c, "one of: `"+strings.Join(s.Enum, "`, `")+"`")
+	}
+	if s.Minimum != 0 {
+		c = append(c, "min: "+strconv.Itoa(s.Minimum))
+	}
+	if s.Maximum != 0 {
+		c = append(c, "max: "+strconv.Itoa(s.Maximum))
+	}
+	if s.Default != "" {
+		c = append(c, "default: `"+s.Default+"`")
+	}
+	if s.Readonly != nil && *s.Readonly {
+		c = append(c, "read-only")
+	}
+	if s.Nullable {
+		c = append(c, "nullable")
+	}
+	return strings.Join(c, ", ")
+}
+
+// link to the definition of the reference ref.
+func (m *writer) link(ref string) string {
+	ref = strings.TrimPrefix(ref, "#/definitions/")
+	if _, ok := m.prog.References[ref]; !ok {
+		return "`" + ref + "`"
+	}
+	return "[" + ref + "](#" + anchor(ref) + ")"
+}
+
+func (m *writer) example(ex interface{}) error {
+	d, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+	m.printf("```json\n%s\n```\n\n", d)
+	return nil
+}
+
+// anchor gets the HTML anchor for a definition.
+func anchor(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+}
+
+// cell escapes s for use in a table cell.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
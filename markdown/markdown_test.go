@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/test"
+	"github.com/teamwork/test/diff"
+)
+
+func TestMarkdown(t *testing.T) {
+	prog := docparse.NewProgram(false)
+	prog.Config.Title = "Objects"
+	prog.Config.Version = "1.0"
+	prog.Config.Basepath = "/api"
+	prog.Config.StructTag = "json"
+	prog.Config.Packages = []string{"./testdata/src/objects"}
+	prog.Config.Output = WriteMarkdown
+
+	w := bytes.NewBufferString("")
+	err := docparse.FindComments(w, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("testdata/want.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.TextDiff(string(want), w.String()); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func TestMarkdownExampleError(t *testing.T) {
+	prog := docparse.NewProgram(false)
+	prog.Endpoints = []*docparse.Endpoint{{
+		Method: "GET",
+		Path:   "/objects",
+		Responses: map[int]docparse.Response{200: {
+			Body:    &docparse.Ref{Description: "200 OK"},
+			Example: func() {},
+		}},
+	}}
+
+	err := WriteMarkdown(io.Discard, prog)
+	if !test.ErrorContains(err, "GET /objects: response 200 example: json: unsupported type") {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package objects
+
+type pathParams struct {
+	ID int `path:"id"` // Object ID.
+}
+
+type queryParams struct {
+	// Sort order {enum: name date, default: name}.
+	Sort string `query:"sort"`
+
+	// Page size {range: 1-100}.
+	Size int `query:"size"`
+
+	// Internal debug flag {omitdoc}.
+	Debug bool `query:"debug"`
+
+	// Old filter | use sort instead.
+	//
+	// Deprecated: use sort.
+	Filter string `query:"filter"`
+}
+
+type responseHeaders struct {
+	// URL of the new object {required}.
+	Location string `header:"Location"`
+}
+
+// An object.
+type object struct {
+	ID     int      `json:"id"`     // Object ID {readonly, required}.
+	Name   string   `json:"name"`   // Name of the object {required}.
+	Owner  *owner   `json:"owner"`  // Owner of the object.
+	Labels []label  `json:"labels"` // Labels.
+	Meta   struct { // Metadata.
+		Created string `json:"created"` // Creation time {date-time}.
+	} `json:"meta"`
+	Extra map[string]string `json:"extra"`
+}
+
+type owner struct {
+	Name string `json:"name"`
+}
+
+type label struct {
+	Name string `json:"name"`
+}
+
+var exampleObject = object{ID: 1, Name: "Bike"}
+
+// GET /objects/{id} objects
+// Get an object.
+//
+// Returns a single object.
+//
+// Path: pathParams
+// Query: queryParams
+// Response 200: object
+// Response 404: {empty}
+
+// GET /labels objects labels
+// List all labels.
+//
+// Response 200 (text/csv): {data}
+
+// POST /objects objects
+// Create an object.
+//
+// Request body: object
+// Request body example: $exampleObject
+// Response 201: object
+// Response 201 headers: responseHeaders
+
+// DELETE /objects/{id}
+// Delete an object.
+//
+// Deprecated: objects can't be deleted.
+// Path: pathParams
+// Response 204: {empty}
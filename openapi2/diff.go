@@ -0,0 +1,440 @@
+package openapi2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/utils/v2/sliceutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Change is a difference between two OpenAPI documents.
+type Change struct {
+	Breaking bool   // Change may break existing clients.
+	Where    string // Endpoint and location, e.g. "GET /path response 200 .id".
+	Message  string
+}
+
+func (c Change) String() string {
+	kind := "non-breaking"
+	if c.Breaking {
+		kind = "breaking"
+	}
+	return fmt.Sprintf("%s: %s: %s", kind, c.Where, c.Message)
+}
+
+// Read an OpenAPI document from a YAML or JSON file.
+func Read(file string) (*OpenAPI, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	unmarshal := yaml.Unmarshal
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		unmarshal = json.Unmarshal
+	}
+
+	var out OpenAPI
+	if err := unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return &out, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	type Alias Operation
+	var v struct {
+		Alias
+		Responses map[string]Response `json:"responses"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Operation(v.Alias)
+	return o.setResponses(v.Responses)
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (o *Operation) UnmarshalYAML(n *yaml.Node) error {
+	// Decode the responses separately, as the "default" key can't be decoded
+	// to an int.
+	var resp map[string]Response
+	if n.Kind == yaml.MappingNode {
+		c := *n
+		c.Content = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "responses" {
+				if err := n.Content[i+1].Decode(&resp); err != nil {
+					return err
+				}
+				continue
+			}
+			c.Content = append(c.Content, n.Content[i], n.Content[i+1])
+		}
+		n = &c
+	}
+
+	type Alias Operation
+	if err := n.Decode((*Alias)(o)); err != nil {
+		return err
+	}
+	return o.setResponses(resp)
+}
+
+func (o *Operation) setResponses(resp map[string]Response) error {
+	if resp == nil {
+		return nil
+	}
+	o.Responses = make(map[int]Response, len(resp))
+	for k, r := range resp {
+		if k == "default" {
+			r := r
+			o.defaultResponse = &r
+			continue
+		}
+		code, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("invalid response code: %q", k)
+		}
+		o.Responses[code] = r
+	}
+	return nil
+}
+
+// Diff gets all changes from the document from to to, sorted by location.
+//
+// Changes are breaking if existing clients may break: removed endpoints,
+// parameters which are new or now required, removed response fields, changed
+// types, request enums which allow fewer values, and response enums with new
+// values.
+func Diff(from, to *OpenAPI) []Change {
+	d := differ{old: from, new: to}
+
+	oldOps, newOps := operations(from), operations(to)
+	for _, k := range sortedKeys(oldOps) {
+		o := oldOps[k]
+		n, ok := newOps[k]
+		if !ok {
+			d.add(true, o.where, "endpoint removed")
+			continue
+		}
+		d.operation(n.where, o, n)
+	}
+	for _, k := range sortedKeys(newOps) {
+		if _, ok := oldOps[k]; !ok {
+			d.add(false, newOps[k].where, "endpoint added")
+		}
+	}
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		return d.changes[i].Where < d.changes[j].Where
+	})
+	return d.changes
+}
+
+type differ struct {
+	old, new *OpenAPI
+	changes  []Change
+}
+
+func (d *differ) add(breaking bool, where, msg string, args ...interface{}) {
+	d.changes = append(d.changes, Change{Breaking: breaking, Where: where, Message: fmt.Sprintf(msg, args...)})
+}
+
+type operation struct {
+	where string
+	path  string // Path template, without the basePath.
+	op    *Operation
+}
+
+// operations gets all operations in doc, keyed by the method and path with
+// the names of path parameters removed; renaming a path parameter doesn't
+// change anything for clients.
+func operations(doc *OpenAPI) map[string]operation {
+	ops := make(map[string]operation)
+	for path, p := range doc.Paths {
+		if p == nil {
+			continue
+		}
+		for _, m := range []struct {
+			method string
+			op     *Operation
+		}{
+			// OpenAPI 2 has no TRACE.
+			{"GET", p.Get}, {"POST", p.Post}, {"PUT", p.Put}, {"PATCH", p.Patch},
+			{"DELETE", p.Delete}, {"HEAD", p.Head}, {"OPTIONS", p.Options},
+		} {
+			if m.op == nil {
+				continue
+			}
+			full := doc.BasePath + path
+			ops[m.method+" "+normalizePath(full)] = operation{where: m.method + " " + full, path: path, op: m.op}
+		}
+	}
+	return ops
+}
+
+// pathParams gets the position of every {param} in the path template.
+func pathParams(path string) map[string]int {
+	pos := make(map[string]int)
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			pos[s[1:len(s)-1]] = len(pos)
+		}
+	}
+	return pos
+}
+
+func normalizePath(path string) string {
+	s := strings.Split(path, "/")
+	for i := range s {
+		if strings.HasPrefix(s[i], "{") && strings.HasSuffix(s[i], "}") {
+			s[i] = "{}"
+		}
+	}
+	return strings.Join(s, "/")
+}
+
+func (d *differ) operation(where string, oldOp, newOp operation) {
+	params := func(op operation) map[string]Parameter {
+		// Names of path parameters and the body don't matter for clients, so
+		// compare these by their position in the path and the body by its
+		// location.
+		pos := pathParams(op.path)
+		m := make(map[string]Parameter)
+		for _, p := range append(op.op.Parameters, op.op.CookieParameters...) {
+			k := p.In + " " + p.Name
+			switch p.In {
+			case "body":
+				k = p.In
+			case "path":
+				if i, ok := pos[p.Name]; ok {
+					k = fmt.Sprintf("path %d", i)
+				}
+			}
+			m[k] = p
+		}
+		return m
+	}
+
+	o, n := oldOp.op, newOp.op
+	oldParams, newParams := params(oldOp), params(newOp)
+	for _, k := range sortedKeys(oldParams) {
+		op := oldParams[k]
+		np, ok := newParams[k]
+		if !ok {
+			d.add(false, where, "%s parameter %q removed", op.In, op.Name)
+			continue
+		}
+		d.parameter(where, op, np)
+	}
+	for _, k := range sortedKeys(newParams) {
+		if _, ok := oldParams[k]; ok {
+			continue
+		}
+		np := newParams[k]
+		if np.Required {
+			d.add(true, where, "required %s parameter %q added", np.In, np.Name)
+		} else {
+			d.add(false, where, "optional %s parameter %q added", np.In, np.Name)
+		}
+	}
+
+	oldResp, newResp := responses(o), responses(n)
+	for _, code := range sortedKeys(oldResp) {
+		nr, ok := newResp[code]
+		if !ok {
+			d.add(true, where, "response %s removed", code)
+			continue
+		}
+		d.schema(fmt.Sprintf("%s response %s", where, code), "", false, oldResp[code].Schema, nr.Schema, nil)
+	}
+	for _, code := range sortedKeys(newResp) {
+		if _, ok := oldResp[code]; !ok {
+			d.add(false, where, "response %s added", code)
+		}
+	}
+}
+
+// responses gets all responses of op keyed by the status code, including the
+// "default" response.
+func responses(op *Operation) map[string]Response {
+	m := make(map[string]Response, len(op.Responses)+1)
+	for code, r := range op.Responses {
+		m[strconv.Itoa(code)] = r
+	}
+	if op.defaultResponse != nil {
+		m["default"] = *op.defaultResponse
+	}
+	return m
+}
+
+func (d *differ) parameter(where string, o, n Parameter) {
+	name := fmt.Sprintf("%s parameter %q", n.In, n.Name)
+	if o.In == "body" {
+		d.schema(where+" request body", "", true, o.Schema, n.Schema, nil)
+		return
+	}
+
+	if !o.Required && n.Required {
+		d.add(true, where, "%s is now required", name)
+	} else if o.Required && !n.Required {
+		d.add(false, where, "%s is now optional", name)
+	}
+
+	d.schema(where+" "+name, "", true,
+		&docparse.Schema{Type: o.Type, Format: o.Format, Enum: o.Enum, Items: o.Items},
+		&docparse.Schema{Type: n.Type, Format: n.Format, Enum: n.Enum, Items: n.Items},
+		nil)
+}
+
+// schema compares the schemas o and n; request is set for schemas which are
+// sent by the client.
+func (d *differ) schema(where, path string, request bool, o, n *docparse.Schema, seen map[string]bool) {
+	if o == nil || n == nil {
+		if (o == nil) != (n == nil) {
+			d.add(true, where, "%sschema changed", prefix(path))
+		}
+		return
+	}
+
+	// Resolve references; only compare each pair once to prevent loops.
+	if o.Reference != "" || n.Reference != "" {
+		k := o.Reference + " " + n.Reference
+		if seen[k] {
+			return
+		}
+		// Only the references on this path are in seen, so a change in a
+		// definition is reported for every field which uses it.
+		branch := make(map[string]bool, len(seen)+1)
+		for s := range seen {
+			branch[s] = true
+		}
+		branch[k] = true
+		seen = branch
+		o, n = d.resolve(d.old, o), d.resolve(d.new, n)
+		if o == nil || n == nil {
+			return
+		}
+	}
+
+	if o.Type != n.Type {
+		d.add(true, where, "%stype changed from %q to %q", prefix(path), o.Type, n.Type)
+		return
+	}
+	if o.Format != n.Format {
+		d.add(true, where, "%sformat changed from %q to %q", prefix(path), o.Format, n.Format)
+	}
+
+	removed, added := enumDiff(o.Enum, n.Enum)
+	switch {
+	case request && len(removed) > 0:
+		d.add(true, where, "%senum values removed: %s", prefix(path), strings.Join(removed, ", "))
+	case !request && len(added) > 0:
+		d.add(true, where, "%senum values added: %s", prefix(path), strings.Join(added, ", "))
+	case len(removed) > 0 || len(added) > 0:
+		d.add(false, where, "%senum values changed", prefix(path))
+	}
+
+	if o.Items != nil || n.Items != nil {
+		d.schema(where, path+"[]", request, o.Items, n.Items, seen)
+	}
+	if o.AdditionalProperties != nil || n.AdditionalProperties != nil {
+		d.schema(where, path+".*", request, o.AdditionalProperties, n.AdditionalProperties, seen)
+	}
+
+	for _, k := range sortedKeys(o.Properties) {
+		field := path + "." + k
+		np, ok := n.Properties[k]
+		if !ok {
+			if request {
+				d.add(false, where, "field %s removed", field)
+			} else {
+				d.add(true, where, "field %s removed", field)
+			}
+			continue
+		}
+
+		wasReq, isReq := sliceutil.Contains(o.Required, k), sliceutil.Contains(n.Required, k)
+		switch {
+		case request && !wasReq && isReq:
+			d.add(true, where, "field %s is now required", field)
+		case !request && wasReq && !isReq:
+			d.add(true, where, "field %s is now optional", field)
+		case wasReq != isReq:
+			d.add(false, where, "field %s required changed", field)
+		}
+
+		d.schema(where, field, request, o.Properties[k], np, seen)
+	}
+	for _, k := range sortedKeys(n.Properties) {
+		if _, ok := o.Properties[k]; ok {
+			continue
+		}
+		field := path + "." + k
+		if request && sliceutil.Contains(n.Required, k) {
+			d.add(true, where, "required field %s added", field)
+		} else {
+			d.add(false, where, "field %s added", field)
+		}
+	}
+}
+
+func (d *differ) resolve(doc *OpenAPI, s *docparse.Schema) *docparse.Schema {
+	if s.Reference == "" {
+		return s
+	}
+	def, ok := doc.Definitions[strings.TrimPrefix(s.Reference, "#/definitions/")]
+	if !ok {
+		return nil
+	}
+	return &def
+}
+
+func prefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+func enumDiff(o, n []string) (removed, added []string) {
+	if len(o) == 0 || len(n) == 0 {
+		// No enum means any value is allowed.
+		if len(o) == 0 && len(n) > 0 {
+			return []string{"(any)"}, n
+		}
+		if len(o) > 0 && len(n) == 0 {
+			return nil, []string{"(any)"}
+		}
+		return nil, nil
+	}
+	for _, v := range o {
+		if !sliceutil.Contains(n, v) {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range n {
+		if !sliceutil.Contains(o, v) {
+			added = append(added, v)
+		}
+	}
+	return removed, added
+}
+
+func sortedKeys[K int | string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,249 @@
+package openapi2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/teamwork/test/diff"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDiff(t *testing.T) {
+	oldSpec := `
+basePath: /v1
+paths:
+  /objects:
+    get:
+      parameters:
+        - {name: sort, in: query, type: string, enum: [name, date]}
+      responses:
+        200:
+          schema: {$ref: '#/definitions/objects'}
+    post:
+      parameters:
+        - name: body
+          in: body
+          schema:
+            type: object
+            required: [name]
+            properties:
+              name: {type: string}
+              color: {type: string, enum: [red, blue]}
+      responses:
+        201:
+          schema: {$ref: '#/definitions/object'}
+        400: {}
+  /objects/{id}:
+    delete:
+      parameters:
+        - {name: id, in: path, type: integer, required: true}
+      responses:
+        204: {}
+definitions:
+  object:
+    type: object
+    properties:
+      id: {type: integer}
+      name: {type: string}
+      state: {type: string, enum: [open, closed]}
+  objects:
+    type: array
+    items: {$ref: '#/definitions/object'}
+`
+	newSpec := `
+basePath: /v1
+paths:
+  /objects:
+    get:
+      parameters:
+        - {name: sort, in: query, type: string, enum: [name]}
+        - {name: page, in: query, type: integer}
+      responses:
+        200:
+          schema: {$ref: '#/definitions/objects'}
+    post:
+      parameters:
+        - name: body
+          in: body
+          schema:
+            type: object
+            required: [name, owner]
+            properties:
+              name: {type: string}
+              color: {type: string, enum: [red, blue, green]}
+              owner: {type: integer}
+              note: {type: string}
+      responses:
+        201:
+          schema: {$ref: '#/definitions/object'}
+  /objects/{objectID}:
+    get:
+      responses:
+        200: {}
+    delete:
+      parameters:
+        - {name: objectID, in: path, type: string, required: true}
+      responses:
+        204: {}
+definitions:
+  object:
+    type: object
+    properties:
+      id: {type: integer}
+      state: {type: string, enum: [open, closed, archived]}
+      created: {type: string, format: date-time}
+  objects:
+    type: array
+    items: {$ref: '#/definitions/object'}
+`
+
+	var o, n OpenAPI
+	if err := yaml.Unmarshal([]byte(oldSpec), &o); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(newSpec), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, c := range Diff(&o, &n) {
+		out = append(out, c.String())
+	}
+	want := []string{
+		`breaking: DELETE /v1/objects/{objectID} path parameter "objectID": type changed from "integer" to "string"`,
+		`non-breaking: GET /v1/objects: optional query parameter "page" added`,
+		`breaking: GET /v1/objects query parameter "sort": enum values removed: date`,
+		`breaking: GET /v1/objects response 200: field [].name removed`,
+		`breaking: GET /v1/objects response 200: [].state: enum values added: archived`,
+		`non-breaking: GET /v1/objects response 200: field [].created added`,
+		`non-breaking: GET /v1/objects/{objectID}: endpoint added`,
+		`breaking: POST /v1/objects: response 400 removed`,
+		`non-breaking: POST /v1/objects request body: .color: enum values changed`,
+		`non-breaking: POST /v1/objects request body: field .note added`,
+		`breaking: POST /v1/objects request body: required field .owner added`,
+		`breaking: POST /v1/objects response 201: field .name removed`,
+		`breaking: POST /v1/objects response 201: .state: enum values added: archived`,
+		`non-breaking: POST /v1/objects response 201: field .created added`,
+	}
+	if d := diff.Diff(want, out); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func TestDiffSharedDefinition(t *testing.T) {
+	spec := func(userProps string) string {
+		return `
+paths:
+  /objects:
+    get:
+      responses:
+        200:
+          schema:
+            type: object
+            properties:
+              owner: {$ref: '#/definitions/user'}
+              editor: {$ref: '#/definitions/user'}
+definitions:
+  user:
+    type: object
+    properties:
+      ` + userProps + `
+      manager: {$ref: '#/definitions/user'}
+`
+	}
+
+	var o, n OpenAPI
+	if err := yaml.Unmarshal([]byte(spec("name: {type: string}")), &o); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(spec("name: {type: integer}")), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, c := range Diff(&o, &n) {
+		out = append(out, c.String())
+	}
+	want := []string{
+		`breaking: GET /objects response 200: .editor.name: type changed from "string" to "integer"`,
+		`breaking: GET /objects response 200: .owner.name: type changed from "string" to "integer"`,
+	}
+	if d := diff.Diff(want, out); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func TestDiffPaths(t *testing.T) {
+	oldSpec := `
+paths:
+  /users/{id}/posts/{postID}:
+    get:
+      parameters:
+        - {name: id, in: path, type: integer, required: true}
+        - {name: postID, in: path, type: string, required: true}
+      responses:
+        200: {}
+        default:
+          schema: {type: object}
+`
+	newSpec := `
+paths:
+  /users/{userID}/posts/{postID}:
+    get:
+      parameters:
+        - {name: postID, in: path, type: string, required: true}
+        - {name: userID, in: path, type: integer, required: true}
+      responses:
+        200: {}
+    options:
+      responses:
+        204: {}
+`
+
+	var o, n OpenAPI
+	if err := yaml.Unmarshal([]byte(oldSpec), &o); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(newSpec), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, c := range Diff(&o, &n) {
+		out = append(out, c.String())
+	}
+	want := []string{
+		`breaking: GET /users/{userID}/posts/{postID}: response default removed`,
+		`non-breaking: OPTIONS /users/{userID}/posts/{postID}: endpoint added`,
+	}
+	if d := diff.Diff(want, out); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.yaml": "swagger: '2.0'\npaths:\n  /x:\n    get:\n      responses:\n        200: {}\n        default: {}\n",
+		"a.json": `{"swagger": "2.0", "paths": {"/x": {"get": {"responses": {"200": {}, "default": {}}}}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+				t.Fatal(err)
+			}
+			out, err := Read(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			op := out.Paths["/x"].Get
+			if _, ok := op.Responses[200]; !ok {
+				t.Errorf("no response 200: %#v", op)
+			}
+			if op.defaultResponse == nil {
+				t.Errorf("no default response: %#v", op)
+			}
+		})
+	}
+}
@@ -35,8 +35,26 @@ type (
 		Tags        []Tag                      `json:"tags,omitempty" yaml:"tags,omitempty"`
 		Paths       map[string]*Path           `json:"paths" yaml:"paths"`
 		Definitions map[string]docparse.Schema `json:"definitions" yaml:"definitions"`
+
+		SecurityDefinitions map[string]SecurityScheme `json:"securityDefinitions,omitempty" yaml:"securityDefinitions,omitempty"`
+		Security            []SecurityRequirement     `json:"security,omitempty" yaml:"security,omitempty"`
+	}
+
+	// SecurityScheme describes a method to authenticate.
+	SecurityScheme struct {
+		Type             string            `json:"type" yaml:"type"` // basic, apiKey, oauth2
+		Description      string            `json:"description,omitempty" yaml:"description,omitempty"`
+		Name             string            `json:"name,omitempty" yaml:"name,omitempty"`
+		In               string            `json:"in,omitempty" yaml:"in,omitempty"`
+		Flow             string            `json:"flow,omitempty" yaml:"flow,omitempty"`
+		AuthorizationURL string            `json:"authorizationUrl,omitempty" yaml:"authorizationUrl,omitempty"`
+		TokenURL         string            `json:"tokenUrl,omitempty" yaml:"tokenUrl,omitempty"`
+		Scopes           map[string]string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
 	}
 
+	// SecurityRequirement lists the scopes required for a security scheme.
+	SecurityRequirement map[string][]string
+
 	// Info provides metadata about the API.
 	Info struct {
 		Title       string  `json:"title,omitempty" yaml:"title,omitempty"`
@@ -67,6 +85,8 @@ type (
 		Minimum     int              `json:"minimum,omitempty" yaml:"minimum,omitempty"`
 		Maximum     int              `json:"maximum,omitempty" yaml:"maximum,omitempty"`
 		Schema      *docparse.Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+		Example     interface{}      `json:"x-example,omitempty" yaml:"x-example,omitempty"`
+		Deprecated  bool             `json:"x-deprecated,omitempty" yaml:"x-deprecated,omitempty"`
 	}
 
 	// Tag adds metadata to a single tag that is used by the Operation type.
@@ -76,13 +96,14 @@ type (
 
 	// Path describes the operations available on a single path.
 	Path struct {
-		Ref    string     `json:"ref,omitempty" yaml:"ref,omitempty"`
-		Get    *Operation `json:"get,omitempty" yaml:"get,omitempty"`
-		Post   *Operation `json:"post,omitempty" yaml:"post,omitempty"`
-		Put    *Operation `json:"put,omitempty" yaml:"put,omitempty"`
-		Patch  *Operation `json:"patch,omitempty" yaml:"patch,omitempty"`
-		Delete *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
-		Head   *Operation `json:"head,omitempty" yaml:"head,omitempty"`
+		Ref     string     `json:"ref,omitempty" yaml:"ref,omitempty"`
+		Get     *Operation `json:"get,omitempty" yaml:"get,omitempty"`
+		Post    *Operation `json:"post,omitempty" yaml:"post,omitempty"`
+		Put     *Operation `json:"put,omitempty" yaml:"put,omitempty"`
+		Patch   *Operation `json:"patch,omitempty" yaml:"patch,omitempty"`
+		Delete  *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+		Head    *Operation `json:"head,omitempty" yaml:"head,omitempty"`
+		Options *Operation `json:"options,omitempty" yaml:"options,omitempty"`
 	}
 
 	// Operation describes a single API operation on a path.
@@ -95,8 +116,21 @@ type (
 		Produces    []string         `json:"produces,omitempty" yaml:"produces,omitempty"`
 		Parameters  []Parameter      `json:"parameters,omitempty" yaml:"parameters,omitempty"`
 		Responses   map[int]Response `json:"responses" yaml:"responses"`
+		Deprecated  bool             `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+
+		// Security is a pointer so that an empty list (i.e. no authentication)
+		// is different from using the default.
+		Security *[]SecurityRequirement `json:"security,omitempty" yaml:"security,omitempty"`
+
+		// CookieParameters are parameters with "in: cookie", which Swagger 2
+		// doesn't support.
+		CookieParameters []Parameter `json:"x-cookie-parameters,omitempty" yaml:"x-cookie-parameters,omitempty"`
 
 		Extend map[string]interface{} `json:"-" yaml:"-"`
+
+		// defaultResponse is the "default" response, which doesn't fit in
+		// Responses; this is only set when reading a document.
+		defaultResponse *Response
 	}
 
 	// Reference other components in the specification, internally and
@@ -107,8 +141,24 @@ type (
 
 	// Response describes a single response from an API Operation.
 	Response struct {
+		Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+		Schema      *docparse.Schema  `json:"schema,omitempty" yaml:"schema,omitempty"`
+		Headers     map[string]Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+
+		// Examples by Content-Type.
+		Examples map[string]interface{} `json:"examples,omitempty" yaml:"examples,omitempty"`
+	}
+
+	// Header describes a single header sent with a response.
+	Header struct {
 		Description string           `json:"description,omitempty" yaml:"description,omitempty"`
-		Schema      *docparse.Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+		Type        string           `json:"type" yaml:"type"`
+		Items       *docparse.Schema `json:"items,omitempty" yaml:"items,omitempty"`
+		Format      string           `json:"format,omitempty" yaml:"format,omitempty"`
+		Enum        []string         `json:"enum,omitempty" yaml:"enum,omitempty"`
+		Default     string           `json:"default,omitempty" yaml:"default,omitempty"`
+		Minimum     int              `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+		Maximum     int              `json:"maximum,omitempty" yaml:"maximum,omitempty"`
 	}
 )
 
@@ -164,6 +214,12 @@ func (o *Operation) MarshalYAML() (interface{}, error) {
 	return &m, nil
 }
 
+func init() {
+	docparse.RegisterOutput("openapi2-yaml", "OpenAPI/Swagger 2.0 as YAML", WriteYAML)
+	docparse.RegisterOutput("openapi2-json", "OpenAPI/Swagger 2.0 as JSON", WriteJSON)
+	docparse.RegisterOutput("openapi2-jsonindent", "OpenAPI/Swagger 2.0 as JSON indented", WriteJSONIndent)
+}
+
 // WriteYAML writes w as YAML.
 func WriteYAML(w io.Writer, prog *docparse.Program) error {
 	return write("yaml", w, prog)
@@ -199,6 +255,16 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 		Definitions: map[string]docparse.Schema{},
 	}
 
+	if len(prog.Config.SecurityScheme) > 0 {
+		out.SecurityDefinitions = make(map[string]SecurityScheme, len(prog.Config.SecurityScheme))
+		for name, s := range prog.Config.SecurityScheme {
+			out.SecurityDefinitions[name] = securityScheme(s)
+		}
+	}
+	if len(prog.Config.DefaultSecurity) > 0 {
+		out.Security = securityRequirements(prog.Config.DefaultSecurity)
+	}
+
 	seenTags := map[string]struct{}{}
 	// track which defs are referenced so we can remove unreferenced ones, e.g embedded
 	// but also handle where it is both embedded and named
@@ -211,8 +277,6 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 
 	// Add endpoints.
 	for _, e := range prog.Endpoints {
-		e.Path = prog.Config.Prefix + e.Path
-
 		op := Operation{
 			Summary:     e.Tagline,
 			Description: e.Info,
@@ -220,6 +284,15 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 			Tags:        e.Tags,
 			Responses:   map[int]Response{},
 			Extend:      e.Extend,
+			Deprecated:  e.Deprecated,
+		}
+		if e.DeprecatedReason != "" {
+			op.Description = strings.TrimSpace(op.Description + "\n\nDeprecated: " + e.DeprecatedReason)
+		}
+
+		if e.Security != nil {
+			sec := securityRequirements(e.Security)
+			op.Security = &sec
 		}
 
 		// Add their tags to the top level object to ensure ordering in
@@ -246,65 +319,38 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 					Description: p.Description,
 					Type:        p.Type,
 					Required:    true,
+					Example:     p.Example,
+					Deprecated:  p.Deprecated,
 				})
 			}
 		}
 
 		// Add query params.
 		if e.Request.Query != nil {
-			// TODO: Don't access prog.References directly. This probably
-			// shouldn't be there anyway.
-			ref := prog.References[e.Request.Query.Reference]
-
-			for _, f := range ref.Fields {
-				// TODO: this should be done in docparse.
-				f.Name = goutil.TagName(f.KindField, "query")
-				if f.Name == "-" {
-					continue
-				}
-
-				schema := ref.Schema.Properties[f.Name]
-				if schema == nil {
-					return fmt.Errorf("schema is nil for query field %q in %q",
-						f.Name, e.Request.Query.Reference)
-				}
-				if schema.OmitDoc {
-					continue
-				}
-
-				queryType := schema.Type
-				if len(queryType) == 0 {
-					// if the parameter is a struct, and not mapped,
-					// we should fallback to a string to have a valid swagger file
-					// (we can not have a field without schema nor type )
-					queryType = "string"
-				}
+			params, err := fieldParams(prog, e.Request.Query, "query")
+			if err != nil {
+				return err
+			}
+			op.Parameters = append(op.Parameters, params...)
+		}
 
-				items := schema.Items
-				if items != nil && len(items.Reference) != 0 {
-					// in swagger 2.0, arrays in the query can only
-					// contain basic type, so, if it holds a reference
-					// we change it to a string
-					items = &docparse.Schema{
-						Type: "string",
-					}
-				}
+		// Add header params.
+		if e.Request.Header != nil {
+			params, err := fieldParams(prog, e.Request.Header, "header")
+			if err != nil {
+				return err
+			}
+			op.Parameters = append(op.Parameters, params...)
+		}
 
-				op.Parameters = append(op.Parameters, Parameter{
-					Name:        f.Name,
-					In:          "query",
-					Description: schema.Description,
-					Type:        queryType,
-					Items:       items,
-					Required:    len(schema.Required) > 0,
-					Readonly:    schema.Readonly,
-					Enum:        schema.Enum,
-					Default:     schema.Default,
-					Minimum:     schema.Minimum,
-					Maximum:     schema.Maximum,
-					Format:      schema.Format,
-				})
+		// Swagger 2 has no cookie parameters, so add them as a vendor
+		// extension.
+		if e.Request.Cookie != nil {
+			params, err := fieldParams(prog, e.Request.Cookie, "cookie")
+			if err != nil {
+				return err
 			}
+			op.CookieParameters = params
 		}
 
 		// Add form params,
@@ -349,6 +395,8 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 					Minimum:     schema.Minimum,
 					Maximum:     schema.Maximum,
 					Format:      schema.Format,
+					Example:     schema.Example,
+					Deprecated:  schema.Deprecated,
 				})
 			}
 			op.Consumes = append(op.Consumes, "application/x-www-form-urlencoded")
@@ -379,6 +427,7 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 				Schema: &docparse.Schema{
 					Reference: ref(e.Request.Body.Reference),
 				},
+				Example: e.Request.Example,
 			})
 			op.Consumes = append(op.Consumes, e.Request.ContentType)
 		}
@@ -389,7 +438,7 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 			left := op.Parameters[i].Type + op.Parameters[i].Name
 			right := op.Parameters[j].Type + op.Parameters[j].Name
 			if left == right {
-				specificOrder := map[string]int64{"path": 0, "query": 1, "formData": 2, "body": 3}
+				specificOrder := map[string]int64{"path": 0, "query": 1, "header": 2, "formData": 3, "body": 4}
 				return specificOrder[op.Parameters[i].In] < specificOrder[op.Parameters[j].In]
 			}
 			return left > right
@@ -414,6 +463,30 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 				}
 			}
 
+			if resp.Example != nil {
+				r.Examples = map[string]interface{}{resp.ContentType: resp.Example}
+			}
+
+			if resp.Headers != nil {
+				params, err := fieldParams(prog, resp.Headers, "header")
+				if err != nil {
+					return err
+				}
+				r.Headers = make(map[string]Header, len(params))
+				for _, p := range params {
+					r.Headers[p.Name] = Header{
+						Description: p.Description,
+						Type:        p.Type,
+						Items:       p.Items,
+						Format:      p.Format,
+						Enum:        p.Enum,
+						Default:     p.Default,
+						Minimum:     p.Minimum,
+						Maximum:     p.Maximum,
+					}
+				}
+			}
+
 			op.Responses[code] = r
 			op.Produces = appendIfNotExists(op.Produces, resp.ContentType)
 		}
@@ -437,6 +510,8 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 			out.Paths[e.Path].Delete = &op
 		case http.MethodHead:
 			out.Paths[e.Path].Head = &op
+		case http.MethodOptions:
+			out.Paths[e.Path].Options = &op
 		default:
 			return fmt.Errorf("unknown method: %#v", e.Method)
 		}
@@ -497,6 +572,120 @@ func write(outFormat string, w io.Writer, prog *docparse.Program) error {
 	return err
 }
 
+// fieldParams converts the fields of the struct referenced by r to a list of
+// parameters of the kind in.
+func fieldParams(prog *docparse.Program, r *docparse.Ref, in string) ([]Parameter, error) {
+	// TODO: Don't access prog.References directly. This probably
+	// shouldn't be there anyway.
+	ref := prog.References[r.Reference]
+
+	var params []Parameter
+	for _, f := range ref.Fields {
+		// TODO: this should be done in docparse.
+		name := goutil.TagName(f.KindField, in)
+		if name == "-" {
+			continue
+		}
+		if name != "" {
+			f.Name = name
+		}
+
+		schema := ref.Schema.Properties[f.Name]
+		if schema == nil {
+			return nil, fmt.Errorf("schema is nil for %s field %q in %q",
+				in, f.Name, r.Reference)
+		}
+		if schema.OmitDoc {
+			continue
+		}
+
+		paramType := schema.Type
+		if len(paramType) == 0 {
+			// if the parameter is a struct, and not mapped,
+			// we should fallback to a string to have a valid swagger file
+			// (we can not have a field without schema nor type )
+			paramType = "string"
+		}
+
+		items := schema.Items
+		if items != nil && len(items.Reference) != 0 {
+			// in swagger 2.0, arrays in the query can only
+			// contain basic type, so, if it holds a reference
+			// we change it to a string
+			items = &docparse.Schema{
+				Type: "string",
+			}
+		}
+
+		params = append(params, Parameter{
+			Name:        f.Name,
+			In:          in,
+			Description: schema.Description,
+			Type:        paramType,
+			Items:       items,
+			Required:    len(schema.Required) > 0,
+			Readonly:    schema.Readonly,
+			Enum:        schema.Enum,
+			Default:     schema.Default,
+			Minimum:     schema.Minimum,
+			Maximum:     schema.Maximum,
+			Format:      schema.Format,
+			Example:     schema.Example,
+			Deprecated:  schema.Deprecated,
+		})
+	}
+	return params, nil
+}
+
+// securityScheme converts s to a Swagger 2 security scheme. Bearer tokens
+// aren't supported, so describe them as an API key in the Authorization header.
+func securityScheme(s docparse.SecurityScheme) SecurityScheme {
+	switch s.Type {
+	case docparse.SecurityBearer:
+		desc := "Bearer token; send as \"Authorization: Bearer <token>\"."
+		if s.BearerFormat != "" {
+			desc = s.BearerFormat + " " + desc
+		}
+		return SecurityScheme{
+			Type:        docparse.SecurityAPIKey,
+			Description: desc,
+			Name:        "Authorization",
+			In:          "header",
+		}
+	case docparse.SecurityOAuth2:
+		scopes := make(map[string]string, len(s.Scopes))
+		for _, sc := range s.Scopes {
+			scopes[sc] = ""
+		}
+		return SecurityScheme{
+			Type:             s.Type,
+			Flow:             s.Flow,
+			AuthorizationURL: s.AuthorizationURL,
+			TokenURL:         s.TokenURL,
+			Scopes:           scopes,
+		}
+	default:
+		return SecurityScheme{
+			Type: s.Type,
+			Name: s.Name,
+			In:   s.In,
+		}
+	}
+}
+
+// securityRequirements converts reqs; every requirement is an alternative.
+func securityRequirements(reqs []docparse.SecurityRequirement) []SecurityRequirement {
+	out := make([]SecurityRequirement, 0, len(reqs))
+	for _, r := range reqs {
+		scopes := r.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		out = append(out, SecurityRequirement{r.Name: scopes})
+	}
+	return out
+}
+
 func makeID(e *docparse.Endpoint) string {
 	return strings.Replace(fmt.Sprintf("%v_%v", e.Method,
 		strings.ReplaceAll(e.Path, "/", "_")), "__", "_", 1)
@@ -0,0 +1,713 @@
+// Package openapi3 outputs to OpenAPI 3.0 and 3.1
+//
+// https://github.com/OAI/OpenAPI-Specification/blob/main/versions/3.0.3.md
+// https://github.com/OAI/OpenAPI-Specification/blob/main/versions/3.1.0.md
+// http://json-schema.org/
+package openapi3 // import "github.com/teamwork/kommentaar/openapi3"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/imdario/mergo"
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/kommentaar/jsonschema"
+	"github.com/teamwork/utils/v2/goutil"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// OpenAPI output.
+	OpenAPI struct {
+		OpenAPI    string           `json:"openapi" yaml:"openapi"`
+		Info       Info             `json:"info" yaml:"info"`
+		Servers    []Server         `json:"servers,omitempty" yaml:"servers,omitempty"`
+		Tags       []Tag            `json:"tags,omitempty" yaml:"tags,omitempty"`
+		Paths      map[string]*Path `json:"paths" yaml:"paths"`
+		Components Components       `json:"components" yaml:"components"`
+
+		Security []SecurityRequirement `json:"security,omitempty" yaml:"security,omitempty"`
+	}
+
+	// Info provides metadata about the API.
+	Info struct {
+		Title       string   `json:"title" yaml:"title"`
+		Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+		Version     string   `json:"version" yaml:"version"`
+		Contact     *Contact `json:"contact,omitempty" yaml:"contact,omitempty"`
+	}
+
+	// Contact provides contact information for the exposed API.
+	Contact struct {
+		Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+		URL   string `json:"url,omitempty" yaml:"url,omitempty"`
+		Email string `json:"email,omitempty" yaml:"email,omitempty"`
+	}
+
+	// Server describes the location of the API.
+	Server struct {
+		URL string `json:"url" yaml:"url"`
+	}
+
+	// Components holds reusable objects for the specification.
+	Components struct {
+		Schemas         map[string]*jsonschema.Schema `json:"schemas" yaml:"schemas"`
+		SecuritySchemes map[string]SecurityScheme     `json:"securitySchemes,omitempty" yaml:"securitySchemes,omitempty"`
+	}
+
+	// SecurityScheme describes a method to authenticate.
+	SecurityScheme struct {
+		Type         string      `json:"type" yaml:"type"` // apiKey, http, oauth2
+		Description  string      `json:"description,omitempty" yaml:"description,omitempty"`
+		Name         string      `json:"name,omitempty" yaml:"name,omitempty"`
+		In           string      `json:"in,omitempty" yaml:"in,omitempty"`
+		Scheme       string      `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+		BearerFormat string      `json:"bearerFormat,omitempty" yaml:"bearerFormat,omitempty"`
+		Flows        *OAuthFlows `json:"flows,omitempty" yaml:"flows,omitempty"`
+	}
+
+	// OAuthFlows lists the supported OAuth2 flows.
+	OAuthFlows struct {
+		Implicit          *OAuthFlow `json:"implicit,omitempty" yaml:"implicit,omitempty"`
+		Password          *OAuthFlow `json:"password,omitempty" yaml:"password,omitempty"`
+		ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty" yaml:"clientCredentials,omitempty"`
+		AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty" yaml:"authorizationCode,omitempty"`
+	}
+
+	// OAuthFlow describes a single OAuth2 flow.
+	OAuthFlow struct {
+		AuthorizationURL string            `json:"authorizationUrl,omitempty" yaml:"authorizationUrl,omitempty"`
+		TokenURL         string            `json:"tokenUrl,omitempty" yaml:"tokenUrl,omitempty"`
+		Scopes           map[string]string `json:"scopes" yaml:"scopes"`
+	}
+
+	// SecurityRequirement lists the scopes required for a security scheme.
+	SecurityRequirement map[string][]string
+
+	// Parameter describes a single operation parameter.
+	Parameter struct {
+		Name        string             `json:"name" yaml:"name"`
+		In          string             `json:"in" yaml:"in"` // query, header, path, cookie
+		Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+		Required    bool               `json:"required,omitempty" yaml:"required,omitempty"`
+		Schema      *jsonschema.Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+		Example     interface{}        `json:"example,omitempty" yaml:"example,omitempty"`
+		Deprecated  bool               `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	}
+
+	// Tag adds metadata to a single tag that is used by the Operation type.
+	Tag struct {
+		Name string `json:"name" yaml:"name"`
+	}
+
+	// Path describes the operations available on a single path.
+	Path struct {
+		Get     *Operation `json:"get,omitempty" yaml:"get,omitempty"`
+		Post    *Operation `json:"post,omitempty" yaml:"post,omitempty"`
+		Put     *Operation `json:"put,omitempty" yaml:"put,omitempty"`
+		Patch   *Operation `json:"patch,omitempty" yaml:"patch,omitempty"`
+		Delete  *Operation `json:"delete,omitempty" yaml:"delete,omitempty"`
+		Head    *Operation `json:"head,omitempty" yaml:"head,omitempty"`
+		Options *Operation `json:"options,omitempty" yaml:"options,omitempty"`
+		Trace   *Operation `json:"trace,omitempty" yaml:"trace,omitempty"`
+	}
+
+	// Operation describes a single API operation on a path.
+	Operation struct {
+		OperationID string              `json:"operationId" yaml:"operationId"`
+		Tags        []string            `json:"tags,omitempty" yaml:"tags,omitempty"`
+		Summary     string              `json:"summary,omitempty" yaml:"summary,omitempty"`
+		Description string              `json:"description,omitempty" yaml:"description,omitempty"`
+		Parameters  []Parameter         `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+		RequestBody *RequestBody        `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+		Responses   map[string]Response `json:"responses" yaml:"responses"`
+		Deprecated  bool                `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+
+		// Security is a pointer so that an empty list (i.e. no authentication)
+		// is different from using the default.
+		Security *[]SecurityRequirement `json:"security,omitempty" yaml:"security,omitempty"`
+
+		Extend map[string]interface{} `json:"-" yaml:"-"`
+	}
+
+	// RequestBody describes a single request body.
+	RequestBody struct {
+		Description string               `json:"description,omitempty" yaml:"description,omitempty"`
+		Required    bool                 `json:"required,omitempty" yaml:"required,omitempty"`
+		Content     map[string]MediaType `json:"content" yaml:"content"`
+	}
+
+	// MediaType provides the schema for a Content-Type.
+	MediaType struct {
+		Schema  *jsonschema.Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+		Example interface{}        `json:"example,omitempty" yaml:"example,omitempty"`
+	}
+
+	// Response describes a single response from an API Operation.
+	Response struct {
+		Description string               `json:"description" yaml:"description"`
+		Headers     map[string]Header    `json:"headers,omitempty" yaml:"headers,omitempty"`
+		Content     map[string]MediaType `json:"content,omitempty" yaml:"content,omitempty"`
+	}
+
+	// Header describes a single header sent with a response.
+	Header struct {
+		Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+		Required    bool               `json:"required,omitempty" yaml:"required,omitempty"`
+		Schema      *jsonschema.Schema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	}
+)
+
+func (o *Operation) toMap() (map[string]interface{}, error) {
+	type Alias Operation
+	data, err := json.Marshal((*Alias)(o))
+	if err != nil {
+		return nil, fmt.Errorf("json marshal: %v", err)
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("json unmarshal: %v", err)
+	}
+
+	if o.Extend != nil {
+		if err := mergo.Merge(&m, o.Extend, mergo.WithOverride); err != nil {
+			return nil, fmt.Errorf("merge extend: %v", err)
+		}
+	}
+	return m, nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	if o.Extend == nil {
+		type Alias Operation
+		return json.Marshal((*Alias)(o))
+	}
+
+	m, err := o.toMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (o *Operation) MarshalYAML() (interface{}, error) {
+	if o.Extend == nil {
+		type Alias Operation
+		return (*Alias)(o), nil
+	}
+
+	m, err := o.toMap()
+	if err != nil {
+		return nil, fmt.Errorf("toMap: %v", err)
+	}
+	return &m, nil
+}
+
+func init() {
+	docparse.RegisterOutput("openapi3-yaml", "OpenAPI 3.0 as YAML", WriteYAML)
+	docparse.RegisterOutput("openapi3-json", "OpenAPI 3.0 as JSON", WriteJSON)
+	docparse.RegisterOutput("openapi3-jsonindent", "OpenAPI 3.0 as JSON indented", WriteJSONIndent)
+	docparse.RegisterOutput("openapi31-yaml", "OpenAPI 3.1 as YAML", WriteYAML31)
+	docparse.RegisterOutput("openapi31-json", "OpenAPI 3.1 as JSON", WriteJSON31)
+	docparse.RegisterOutput("openapi31-jsonindent", "OpenAPI 3.1 as JSON indented", WriteJSONIndent31)
+}
+
+// WriteYAML writes w as OpenAPI 3.0 YAML.
+func WriteYAML(w io.Writer, prog *docparse.Program) error {
+	return write(version30, "yaml", w, prog)
+}
+
+// WriteJSON writes to w as OpenAPI 3.0 JSON.
+func WriteJSON(w io.Writer, prog *docparse.Program) error {
+	return write(version30, "json", w, prog)
+}
+
+// WriteJSONIndent writes to w as OpenAPI 3.0 indented JSON.
+func WriteJSONIndent(w io.Writer, prog *docparse.Program) error {
+	return write(version30, "jsonindent", w, prog)
+}
+
+// WriteYAML31 writes w as OpenAPI 3.1 YAML.
+func WriteYAML31(w io.Writer, prog *docparse.Program) error {
+	return write(version31, "yaml", w, prog)
+}
+
+// WriteJSON31 writes to w as OpenAPI 3.1 JSON.
+func WriteJSON31(w io.Writer, prog *docparse.Program) error {
+	return write(version31, "json", w, prog)
+}
+
+// WriteJSONIndent31 writes to w as OpenAPI 3.1 indented JSON.
+func WriteJSONIndent31(w io.Writer, prog *docparse.Program) error {
+	return write(version31, "jsonindent", w, prog)
+}
+
+const (
+	refPrefix = "#/components/schemas/"
+
+	version30 = "3.0.3"
+	version31 = "3.1.0"
+)
+
+func write(version, outFormat string, w io.Writer, prog *docparse.Program) error {
+	out := OpenAPI{
+		OpenAPI: version,
+		Info: Info{
+			Title:       prog.Config.Title,
+			Description: string(prog.Config.Description),
+			Version:     prog.Config.Version,
+		},
+		Paths:      map[string]*Path{},
+		Components: Components{Schemas: map[string]*jsonschema.Schema{}},
+	}
+	if prog.Config.ContactName != "" || prog.Config.ContactEmail != "" || prog.Config.ContactSite != "" {
+		out.Info.Contact = &Contact{
+			Name:  prog.Config.ContactName,
+			Email: prog.Config.ContactEmail,
+			URL:   prog.Config.ContactSite,
+		}
+	}
+	if prog.Config.Basepath != "" {
+		out.Servers = []Server{{URL: prog.Config.Basepath}}
+	}
+
+	if len(prog.Config.SecurityScheme) > 0 {
+		out.Components.SecuritySchemes = make(map[string]SecurityScheme, len(prog.Config.SecurityScheme))
+		for name, s := range prog.Config.SecurityScheme {
+			out.Components.SecuritySchemes[name] = securityScheme(s)
+		}
+	}
+	if len(prog.Config.DefaultSecurity) > 0 {
+		out.Security = securityRequirements(prog.Config.DefaultSecurity)
+	}
+
+	seenTags := map[string]struct{}{}
+	// Track which schemas are referenced so we can remove unreferenced ones,
+	// e.g. embedded structs.
+	referencedDefs := map[string]struct{}{}
+	ref := func(s string) string {
+		s = strings.TrimPrefix(s, refPrefix)
+		s = strings.TrimPrefix(s, "#/definitions/")
+		referencedDefs[s] = struct{}{}
+		return refPrefix + s
+	}
+
+	dialect := jsonschema.OpenAPI30
+	if version == version31 {
+		dialect = jsonschema.Draft202012
+	}
+	conv := func(s *docparse.Schema) *jsonschema.Schema {
+		return jsonschema.Convert(s, dialect, ref)
+	}
+
+	for _, e := range prog.Endpoints {
+		path := e.Path
+
+		op := Operation{
+			Summary:     e.Tagline,
+			Description: e.Info,
+			OperationID: makeID(e.Method, path),
+			Tags:        e.Tags,
+			Responses:   map[string]Response{},
+			Extend:      e.Extend,
+			Deprecated:  e.Deprecated,
+		}
+		if e.DeprecatedReason != "" {
+			op.Description = strings.TrimSpace(op.Description + "\n\nDeprecated: " + e.DeprecatedReason)
+		}
+
+		if e.Security != nil {
+			sec := securityRequirements(e.Security)
+			op.Security = &sec
+		}
+
+		for _, t := range e.Tags {
+			seenTags[t] = struct{}{}
+		}
+
+		// Add path params.
+		if e.Request.Path != nil {
+			pathRef := prog.References[e.Request.Path.Reference]
+			for name, p := range pathRef.Schema.Properties {
+				desc := p.Description
+				if p.OmitDoc {
+					// path is required, so just blank description.
+					desc = ""
+				}
+
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:        name,
+					In:          "path",
+					Description: desc,
+					Required:    true,
+					Schema:      paramSchema(p, conv),
+					Example:     p.Example,
+					Deprecated:  p.Deprecated,
+				})
+			}
+		}
+
+		if e.Request.Query != nil {
+			params, err := fieldParams(prog, e.Request.Query, "query", conv)
+			if err != nil {
+				return err
+			}
+			op.Parameters = append(op.Parameters, params...)
+		}
+		if e.Request.Header != nil {
+			params, err := fieldParams(prog, e.Request.Header, "header", conv)
+			if err != nil {
+				return err
+			}
+			op.Parameters = append(op.Parameters, params...)
+		}
+		if e.Request.Cookie != nil {
+			params, err := fieldParams(prog, e.Request.Cookie, "cookie", conv)
+			if err != nil {
+				return err
+			}
+			op.Parameters = append(op.Parameters, params...)
+		}
+
+		// Add any {..} parameters in the path to the parameter list if they
+		// haven't been specified manually in e.Request.Path.
+		if e.Request.Path == nil {
+			for _, param := range docparse.PathParams(path) {
+				op.Parameters = append(op.Parameters, Parameter{
+					Name:     param,
+					In:       "path",
+					Required: true,
+					Schema:   &jsonschema.Schema{Type: "integer"},
+				})
+			}
+		}
+
+		// TODO: preserve order in which they were defined in the struct, but
+		// for now sort it like this so the output is stable.
+		sort.SliceStable(op.Parameters, func(i, j int) bool {
+			order := map[string]int{"path": 0, "query": 1, "header": 2, "cookie": 3}
+			if op.Parameters[i].In != op.Parameters[j].In {
+				return order[op.Parameters[i].In] < order[op.Parameters[j].In]
+			}
+			return op.Parameters[i].Name < op.Parameters[j].Name
+		})
+
+		if e.Request.Body != nil || e.Request.Form != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{},
+			}
+		}
+		if e.Request.Body != nil {
+			op.RequestBody.Description = e.Request.Body.Description
+			op.RequestBody.Content[e.Request.ContentType] = MediaType{
+				Schema:  &jsonschema.Schema{Reference: ref(e.Request.Body.Reference)},
+				Example: e.Request.Example,
+			}
+		}
+		if e.Request.Form != nil {
+			schema, err := formSchema(prog, e.Request.Form, conv)
+			if err != nil {
+				return err
+			}
+			op.RequestBody.Content["application/x-www-form-urlencoded"] = MediaType{
+				Schema: schema,
+			}
+		}
+
+		for code, resp := range e.Responses {
+			r := Response{Description: resp.Body.Description}
+
+			var schema *jsonschema.Schema
+			switch {
+			case resp.Body.Reference != "":
+				schema = &jsonschema.Schema{Reference: ref(resp.Body.Reference)}
+			case isEmpty(resp):
+				// {empty}; no content.
+			default:
+				if dr, ok := prog.Config.DefaultResponse[code]; ok {
+					schema = &jsonschema.Schema{Reference: ref(dr.Body.Reference)}
+					if dr.ContentType != "" {
+						resp.ContentType = dr.ContentType
+					}
+				}
+			}
+
+			// {empty} responses have no content at all; {data} responses have
+			// a Content-Type but no schema.
+			if !isEmpty(resp) {
+				r.Content = map[string]MediaType{
+					resp.ContentType: {Schema: schema, Example: resp.Example},
+				}
+			}
+
+			if resp.Headers != nil {
+				params, err := fieldParams(prog, resp.Headers, "header", conv)
+				if err != nil {
+					return err
+				}
+				r.Headers = make(map[string]Header, len(params))
+				for _, p := range params {
+					r.Headers[p.Name] = Header{
+						Description: p.Description,
+						Required:    p.Required,
+						Schema:      p.Schema,
+					}
+				}
+			}
+
+			op.Responses[strconv.Itoa(code)] = r
+		}
+
+		if out.Paths[path] == nil {
+			out.Paths[path] = &Path{}
+		}
+
+		switch e.Method {
+		case http.MethodGet:
+			out.Paths[path].Get = &op
+		case http.MethodPost:
+			out.Paths[path].Post = &op
+		case http.MethodPut:
+			out.Paths[path].Put = &op
+		case http.MethodPatch:
+			out.Paths[path].Patch = &op
+		case http.MethodDelete:
+			out.Paths[path].Delete = &op
+		case http.MethodHead:
+			out.Paths[path].Head = &op
+		case http.MethodOptions:
+			out.Paths[path].Options = &op
+		case http.MethodTrace:
+			out.Paths[path].Trace = &op
+		default:
+			return fmt.Errorf("unknown method: %#v", e.Method)
+		}
+	}
+
+	if len(seenTags) > 0 {
+		out.Tags = make([]Tag, 0, len(seenTags))
+		for tag := range seenTags {
+			out.Tags = append(out.Tags, Tag{Name: tag})
+		}
+		sort.Slice(out.Tags, func(i int, j int) bool {
+			return out.Tags[i].Name < out.Tags[j].Name
+		})
+	}
+
+	// Add schemas.
+	for k, v := range prog.References {
+		if v.Schema == nil {
+			return fmt.Errorf("schema is nil for %q", k)
+		}
+		out.Components.Schemas[k] = conv(v.Schema)
+	}
+	for k := range out.Components.Schemas {
+		if _, ok := referencedDefs[k]; !ok {
+			delete(out.Components.Schemas, k)
+		}
+	}
+
+	return encode(outFormat, w, &out)
+}
+
+// fieldParams converts the fields of the struct referenced by r to a list of
+// parameters of the kind in.
+func fieldParams(
+	prog *docparse.Program,
+	r *docparse.Ref,
+	in string,
+	conv func(*docparse.Schema) *jsonschema.Schema,
+) ([]Parameter, error) {
+	// TODO: Don't access prog.References directly.
+	ref := prog.References[r.Reference]
+
+	var params []Parameter
+	for _, f := range ref.Fields {
+		name := goutil.TagName(f.KindField, in)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := ref.Schema.Properties[name]
+		if schema == nil {
+			return nil, fmt.Errorf("schema is nil for %s field %q in %q",
+				in, name, r.Reference)
+		}
+		if schema.OmitDoc {
+			continue
+		}
+
+		params = append(params, Parameter{
+			Name:        name,
+			In:          in,
+			Description: schema.Description,
+			Required:    len(schema.Required) > 0,
+			Schema:      paramSchema(schema, conv),
+			Example:     schema.Example,
+			Deprecated:  schema.Deprecated,
+		})
+	}
+	return params, nil
+}
+
+// paramSchema gets the schema for a parameter from the property schema; the
+// description, required, example, and deprecated are set on the parameter
+// instead. Parameters are never null, so Nullable is ignored.
+func paramSchema(p *docparse.Schema, conv func(*docparse.Schema) *jsonschema.Schema) *jsonschema.Schema {
+	c := *p
+	c.Description = ""
+	c.Required = nil
+	c.Nullable = false
+	c.Example = nil
+	c.Deprecated = false
+
+	s := conv(&c)
+	if s.Type == nil && s.Reference == "" {
+		// Struct which isn't mapped; fall back to a string as there is no
+		// way to know how it's encoded.
+		s.Type = "string"
+	}
+	return s
+}
+
+// formSchema creates an inline object schema for the form parameters.
+func formSchema(
+	prog *docparse.Program,
+	r *docparse.Ref,
+	conv func(*docparse.Schema) *jsonschema.Schema,
+) (*jsonschema.Schema, error) {
+	ref := prog.References[r.Reference]
+
+	s := &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{},
+	}
+	for _, f := range ref.Fields {
+		name := goutil.TagName(f.KindField, "form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := ref.Schema.Properties[name]
+		if prop == nil {
+			return nil, fmt.Errorf("schema is nil for form field %q in %q",
+				name, r.Reference)
+		}
+		if prop.OmitDoc {
+			continue
+		}
+
+		if len(prop.Required) > 0 {
+			s.Required = append(s.Required, name)
+		}
+		p := *prop
+		p.Required = nil
+		p.Nullable = false
+		s.Properties[name] = conv(&p)
+	}
+	return s, nil
+}
+
+// securityScheme converts s to an OpenAPI 3 security scheme.
+func securityScheme(s docparse.SecurityScheme) SecurityScheme {
+	switch s.Type {
+	case docparse.SecurityBasic:
+		return SecurityScheme{Type: "http", Scheme: "basic"}
+	case docparse.SecurityBearer:
+		return SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: s.BearerFormat}
+	case docparse.SecurityOAuth2:
+		flow := &OAuthFlow{
+			AuthorizationURL: s.AuthorizationURL,
+			TokenURL:         s.TokenURL,
+			Scopes:           make(map[string]string, len(s.Scopes)),
+		}
+		for _, sc := range s.Scopes {
+			flow.Scopes[sc] = ""
+		}
+
+		flows := &OAuthFlows{}
+		switch s.Flow {
+		case docparse.FlowImplicit:
+			flows.Implicit = flow
+		case docparse.FlowPassword:
+			flows.Password = flow
+		case docparse.FlowApplication:
+			flows.ClientCredentials = flow
+		case docparse.FlowAccessCode:
+			flows.AuthorizationCode = flow
+		}
+		return SecurityScheme{Type: s.Type, Flows: flows}
+	default:
+		return SecurityScheme{Type: s.Type, Name: s.Name, In: s.In}
+	}
+}
+
+// securityRequirements converts reqs; every requirement is an alternative.
+func securityRequirements(reqs []docparse.SecurityRequirement) []SecurityRequirement {
+	out := make([]SecurityRequirement, 0, len(reqs))
+	for _, r := range reqs {
+		scopes := r.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		out = append(out, SecurityRequirement{r.Name: scopes})
+	}
+	return out
+}
+
+// isEmpty reports if this is an {empty} response.
+func isEmpty(resp docparse.Response) bool {
+	return resp.Body.Reference == "" && resp.Keyword == "{empty}"
+}
+
+func encode(outFormat string, w io.Writer, out interface{}) error {
+	var (
+		d   []byte
+		err error
+	)
+	switch outFormat {
+	case "jsonindent":
+		d, err = json.MarshalIndent(out, "", "  ")
+	case "json":
+		d, err = json.Marshal(out)
+	case "yaml":
+		var b bytes.Buffer
+		yamlEncoder := yaml.NewEncoder(&b)
+		yamlEncoder.SetIndent(2)
+		err = yamlEncoder.Encode(out)
+		d = b.Bytes()
+	default:
+		err = fmt.Errorf("unknown format: %#v", outFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(d)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("\n"))
+	return err
+}
+
+func makeID(method, path string) string {
+	return strings.Replace(fmt.Sprintf("%v_%v", method,
+		strings.ReplaceAll(path, "/", "_")), "__", "_", 1)
+}
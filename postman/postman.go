@@ -0,0 +1,454 @@
+// Package postman outputs to a Postman collection.
+//
+// https://schema.postman.com/collection/json/v2.1.0/draft-07/docs/index.html
+package postman // import "github.com/teamwork/kommentaar/postman"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/kommentaar/jsonschema"
+	"github.com/teamwork/utils/v2/goutil"
+)
+
+// SchemaURL is the URL of the Postman collection v2.1 schema.
+const SchemaURL = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// Collection is a Postman collection.
+type Collection struct {
+	Info     Info       `json:"info"`
+	Item     []Item     `json:"item"`
+	Variable []Variable `json:"variable,omitempty"`
+}
+
+// Info about the collection.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Schema      string `json:"schema"`
+}
+
+// Item is either a folder with more items, or a request.
+type Item struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Item        []Item     `json:"item,omitempty"`
+	Request     *Request   `json:"request,omitempty"`
+	Response    []Response `json:"response,omitempty"`
+}
+
+// Request to send.
+type Request struct {
+	Method      string     `json:"method"`
+	Header      []KeyValue `json:"header"`
+	Body        *Body      `json:"body,omitempty"`
+	URL         URL        `json:"url"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Response is an example response.
+type Response struct {
+	Name   string     `json:"name"`
+	Status string     `json:"status"`
+	Code   int        `json:"code"`
+	Header []KeyValue `json:"header,omitempty"`
+	Body   string     `json:"body,omitempty"`
+
+	PreviewLanguage string `json:"_postman_previewlanguage,omitempty"`
+}
+
+// URL of a request.
+type URL struct {
+	Raw      string     `json:"raw"`
+	Host     []string   `json:"host"`
+	Path     []string   `json:"path,omitempty"`
+	Query    []KeyValue `json:"query,omitempty"`
+	Variable []Variable `json:"variable,omitempty"`
+}
+
+// KeyValue is a header, query parameter, or form parameter.
+type KeyValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+}
+
+// Variable for the collection or a path.
+type Variable struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+// Body of a request.
+type Body struct {
+	Mode       string       `json:"mode"` // raw or urlencoded
+	Raw        string       `json:"raw,omitempty"`
+	URLEncoded []KeyValue   `json:"urlencoded,omitempty"`
+	Options    *BodyOptions `json:"options,omitempty"`
+}
+
+// BodyOptions sets the language for a raw body.
+type BodyOptions struct {
+	Raw struct {
+		Language string `json:"language"`
+	} `json:"raw"`
+}
+
+func init() {
+	docparse.RegisterOutput("postman", "Postman collection v2.1", WriteJSON)
+}
+
+// WriteJSON writes prog as a Postman collection to w.
+//
+// Requests are grouped in a folder for every tag of the endpoint, so endpoints
+// with more than one tag are in more than one folder. All URLs start with a
+// {{baseUrl}} variable, which is set to the basepath and prefix.
+func WriteJSON(w io.Writer, prog *docparse.Program) error {
+	out := Collection{
+		Info: Info{
+			Name:        prog.Config.Title,
+			Description: string(prog.Config.Description),
+			Version:     prog.Config.Version,
+			Schema:      SchemaURL,
+		},
+		Item: []Item{},
+	}
+	if out.Info.Name == "" {
+		out.Info.Name = "API"
+	}
+
+	// The paths already have the prefix; only use it in the base URL if all
+	// paths have it (RewritePrefix may have changed some).
+	prefix := strings.TrimSuffix(prog.Config.Prefix, "/")
+	for _, e := range prog.Endpoints {
+		if !hasPrefix(e.Path, prefix) {
+			prefix = ""
+			break
+		}
+	}
+	out.Variable = []Variable{{Key: "baseUrl", Value: prog.Config.Basepath + prefix}}
+
+	folders := make(map[string]int)
+	for _, e := range prog.Endpoints {
+		item, err := request(prog, e, strings.TrimPrefix(e.Path, prefix))
+		if err != nil {
+			return fmt.Errorf("%s %s: %v", e.Method, e.Path, err)
+		}
+
+		if len(e.Tags) == 0 {
+			out.Item = append(out.Item, item)
+			continue
+		}
+		for _, tag := range e.Tags {
+			i, ok := folders[tag]
+			if !ok {
+				i = len(out.Item)
+				folders[tag] = i
+				out.Item = append(out.Item, Item{Name: tag})
+			}
+			out.Item[i].Item = append(out.Item[i].Item, item)
+		}
+	}
+
+	d, err := marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(d, '\n'))
+	return err
+}
+
+// hasPrefix reports if path starts with the path segments of prefix, so that
+// /v10/x doesn't have the prefix /v1. The prefix shouldn't end with a "/".
+func hasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// marshal v as indented JSON, without escaping & and < in URLs and examples.
+func marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), err
+}
+
+func request(prog *docparse.Program, e *docparse.Endpoint, path string) (Item, error) {
+	item := Item{
+		Name: e.Tagline,
+		Request: &Request{
+			Method:      e.Method,
+			Header:      []KeyValue{},
+			Description: e.Info,
+		},
+	}
+	if item.Name == "" {
+		item.Name = e.Method + " " + e.Path
+	}
+	if e.Deprecated {
+		reason := "Deprecated."
+		if e.DeprecatedReason != "" {
+			reason = "Deprecated: " + e.DeprecatedReason
+		}
+		item.Request.Description = strings.TrimSpace(item.Request.Description + "\n\n" + reason)
+	}
+
+	// Path variables; {id} is :id in Postman.
+	reqURL := URL{Host: []string{"{{baseUrl}}"}}
+	pathParams, err := params(prog, e.Request.Path, "path")
+	if err != nil {
+		return item, err
+	}
+	for _, s := range strings.Split(strings.Trim(path, "/"), "/") {
+		for _, p := range docparse.PathParams(s) {
+			s = strings.Replace(s, "{"+p+"}", ":"+p, 1)
+		}
+		if s != "" {
+			reqURL.Path = append(reqURL.Path, s)
+		}
+	}
+	for _, p := range docparse.PathParams(path) {
+		v := Variable{Key: p}
+		for _, pp := range pathParams {
+			if pp.Key == p {
+				v.Value, v.Description = pp.Value, pp.Description
+			}
+		}
+		reqURL.Variable = append(reqURL.Variable, v)
+	}
+
+	reqURL.Query, err = params(prog, e.Request.Query, "query")
+	if err != nil {
+		return item, err
+	}
+	reqURL.Raw = "{{baseUrl}}/" + strings.Join(reqURL.Path, "/")
+	var query []string
+	for _, q := range reqURL.Query {
+		if !q.Disabled {
+			query = append(query, url.QueryEscape(q.Key)+"="+url.QueryEscape(q.Value))
+		}
+	}
+	if len(query) > 0 {
+		reqURL.Raw += "?" + strings.Join(query, "&")
+	}
+	item.Request.URL = reqURL
+
+	headers, err := params(prog, e.Request.Header, "header")
+	if err != nil {
+		return item, err
+	}
+	cookies, err := params(prog, e.Request.Cookie, "cookie")
+	if err != nil {
+		return item, err
+	}
+	if len(cookies) > 0 {
+		headers = append(headers, cookieHeader(cookies))
+	}
+
+	switch {
+	case e.Request.Form != nil:
+		form, err := params(prog, e.Request.Form, "form")
+		if err != nil {
+			return item, err
+		}
+		item.Request.Header = append(item.Request.Header,
+			KeyValue{Key: "Content-Type", Value: "application/x-www-form-urlencoded"})
+		item.Request.Body = &Body{Mode: "urlencoded", URLEncoded: form}
+	case e.Request.Body != nil:
+		ex := e.Request.Example
+		if ex == nil {
+			ex = sample(prog, &docparse.Schema{Reference: e.Request.Body.Reference}, map[string]bool{})
+		}
+		raw, err := marshal(ex)
+		if err != nil {
+			return item, err
+		}
+		item.Request.Header = append(item.Request.Header,
+			KeyValue{Key: "Content-Type", Value: e.Request.ContentType})
+		item.Request.Body = &Body{Mode: "raw", Raw: string(raw), Options: &BodyOptions{}}
+		item.Request.Body.Options.Raw.Language = "json"
+	}
+	item.Request.Header = append(item.Request.Header, headers...)
+
+	// Add responses with examples as saved responses.
+	codes := make([]int, 0, len(e.Responses))
+	for code := range e.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		r := e.Responses[code]
+		if r.Example == nil {
+			continue
+		}
+		body, err := marshal(r.Example)
+		if err != nil {
+			return item, err
+		}
+		item.Response = append(item.Response, Response{
+			Name:            fmt.Sprintf("%d %s", code, http.StatusText(code)),
+			Status:          http.StatusText(code),
+			Code:            code,
+			Header:          []KeyValue{{Key: "Content-Type", Value: r.ContentType}},
+			Body:            string(body),
+			PreviewLanguage: "json",
+		})
+	}
+
+	return item, nil
+}
+
+// params gets the parameters for the struct referenced by r, in the order
+// they're defined. Parameters are disabled unless they're required or have a
+// default.
+func params(prog *docparse.Program, r *docparse.Ref, in string) ([]KeyValue, error) {
+	if r == nil {
+		return nil, nil
+	}
+	ref, ok := prog.References[r.Reference]
+	if !ok || ref.Schema == nil {
+		return nil, fmt.Errorf("no schema for %q", r.Reference)
+	}
+
+	var out []KeyValue
+	for _, f := range ref.Fields {
+		name := goutil.TagName(f.KindField, in)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := ref.Schema.Properties[name]
+		if schema == nil {
+			return nil, fmt.Errorf("schema is nil for %s field %q in %q", in, name, r.Reference)
+		}
+		if schema.OmitDoc && in != "path" {
+			continue
+		}
+
+		p := KeyValue{
+			Key:         name,
+			Value:       value(schema),
+			Description: schema.Description,
+			Disabled:    in != "path" && len(schema.Required) == 0 && schema.Default == "",
+		}
+		if schema.OmitDoc {
+			p.Description = ""
+		}
+		if len(schema.Enum) > 0 {
+			p.Description = strings.TrimSpace(p.Description + " One of: " + strings.Join(schema.Enum, ", ") + ".")
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// cookieHeader gets a Cookie header for the cookie parameters; it's only
+// enabled if one of the cookies is.
+func cookieHeader(cookies []KeyValue) KeyValue {
+	var (
+		values, all, desc []string
+		disabled          = true
+	)
+	for _, c := range cookies {
+		v := c.Key + "=" + c.Value
+		all = append(all, v)
+		if !c.Disabled {
+			values = append(values, v)
+			disabled = false
+		}
+		if c.Description != "" {
+			desc = append(desc, c.Key+": "+c.Description)
+		}
+	}
+	if disabled {
+		values = all
+	}
+	return KeyValue{
+		Key:         "Cookie",
+		Value:       strings.Join(values, "; "),
+		Description: strings.Join(desc, "\n"),
+		Disabled:    disabled,
+	}
+}
+
+// value gets the value to pre-fill for a parameter: the default, example, or
+// first enum value.
+func value(s *docparse.Schema) string {
+	switch {
+	case s.Default != "":
+		return s.Default
+	case s.Example != nil:
+		return fmt.Sprintf("%v", s.Example)
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	return ""
+}
+
+// sample gets a sample value for the schema s: the example, default, first
+// enum value, or the zero value for the type.
+//
+// References which are already being expanded in seen are returned as null,
+// to prevent loops with recursive types.
+func sample(prog *docparse.Program, s *docparse.Schema, seen map[string]bool) interface{} {
+	if s.Reference != "" {
+		name := strings.TrimPrefix(s.Reference, "#/definitions/")
+		ref, ok := prog.References[name]
+		if !ok || ref.Schema == nil || seen[name] {
+			return nil
+		}
+		seen[name] = true
+		defer delete(seen, name)
+		return sample(prog, ref.Schema, seen)
+	}
+
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != "":
+		return jsonschema.TypedValue(s.Type, s.Default)
+	case len(s.Enum) > 0:
+		return jsonschema.TypedValue(s.Type, s.Enum[0])
+	}
+
+	switch s.Type {
+	case "array":
+		if s.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{sample(prog, s.Items, seen)}
+	case "string", "enum":
+		return ""
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "object", "":
+		if s.AdditionalProperties != nil && len(s.Properties) == 0 {
+			return map[string]interface{}{}
+		}
+		obj := make(map[string]interface{}, len(s.Properties))
+		for name, p := range s.Properties {
+			if p.OmitDoc || (p.Readonly != nil && *p.Readonly) {
+				continue
+			}
+			obj[name] = sample(prog, p, seen)
+		}
+		return obj
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/teamwork/kommentaar/docparse"
+	"github.com/teamwork/test/diff"
+)
+
+func TestPostman(t *testing.T) {
+	prog := docparse.NewProgram(false)
+	prog.Config.Title = "Objects"
+	prog.Config.Version = "1.0"
+	prog.Config.Basepath = "/api"
+	prog.Config.Prefix = "/v1"
+	prog.Config.StructTag = "json"
+	prog.Config.Packages = []string{"./testdata/src/objects"}
+	prog.Config.Output = WriteJSON
+
+	w := bytes.NewBufferString("")
+	err := docparse.FindComments(w, prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("testdata/want.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := diff.TextDiff(string(want), w.String()); d != "" {
+		t.Errorf("\n%s", d)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		paths  []string
+		want   string
+		raw    string
+	}{
+		{"/v1", []string{"/v1/a", "/v1/b/c"}, "/api/v1", "{{baseUrl}}/a"},
+		{"/v1", []string{"/v1/a", "/v1"}, "/api/v1", "{{baseUrl}}/a"},
+		{"/v1", []string{"/v1/a", "/v10/b"}, "/api", "{{baseUrl}}/v1/a"},
+		{"/v1", []string{"/v1/a", "/b"}, "/api", "{{baseUrl}}/v1/a"},
+		{"/v1/", []string{"/v1/a", "/v1/b/c"}, "/api/v1", "{{baseUrl}}/a"},
+		{"/v1/", []string{"/v1/a", "/b"}, "/api", "{{baseUrl}}/v1/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+strings.Join(tt.paths, " "), func(t *testing.T) {
+			prog := docparse.NewProgram(false)
+			prog.Config.Basepath = "/api"
+			prog.Config.Prefix = tt.prefix
+			for _, p := range tt.paths {
+				prog.Endpoints = append(prog.Endpoints, &docparse.Endpoint{Method: "GET", Path: p})
+			}
+
+			w := bytes.NewBufferString("")
+			if err := WriteJSON(w, prog); err != nil {
+				t.Fatal(err)
+			}
+			var out Collection
+			if err := json.Unmarshal(w.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Variable[0].Value != tt.want {
+				t.Errorf("baseUrl is %q; want %q", out.Variable[0].Value, tt.want)
+			}
+			if raw := out.Item[0].Request.URL.Raw; raw != tt.raw {
+				t.Errorf("URL is %q; want %q", raw, tt.raw)
+			}
+		})
+	}
+}
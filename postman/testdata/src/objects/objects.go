@@ -0,0 +1,108 @@
+package objects
+
+type pathParams struct {
+	ID int `path:"id"` // Object ID.
+}
+
+type queryParams struct {
+	// Sort order {enum: name date, default: name}.
+	Sort string `query:"sort"`
+
+	// Object state {enum: active archived}.
+	State string `query:"state"`
+
+	// Page size {range: 1-100, required}.
+	Size int `query:"size"`
+
+	// Internal debug flag {omitdoc}.
+	Debug bool `query:"debug"`
+
+	// Search text {default: a&b}.
+	Q string `query:"q"`
+}
+
+type headerParams struct {
+	// Request ID for tracing.
+	RequestID string `header:"X-Request-Id"`
+}
+
+type cookieParams struct {
+	// Session token {required}.
+	Session string `cookie:"session"`
+
+	// UI theme {enum: light dark}.
+	Theme string `cookie:"theme"`
+}
+
+type formParams struct {
+	// Name of the object {required}.
+	Name string `form:"name"`
+
+	// Colour {enum: red blue}.
+	Colour string `form:"colour"`
+}
+
+// An object.
+type object struct {
+	ID     int      `json:"id"`     // Object ID {readonly, required}.
+	Name   string   `json:"name"`   // Name of the object {required}.
+	Count  int      `json:"count"`  // Number of things {default: 5}.
+	Active bool     `json:"active"` // Active?
+	Owner  *owner   `json:"owner"`  // Owner of the object.
+	Labels []label  `json:"labels"` // Labels.
+	Meta   struct { // Metadata.
+		Created string `json:"created"` // Creation time {date-time}.
+	} `json:"meta"`
+}
+
+type owner struct {
+	Name   string `json:"name"`   // {example: Alice}
+	Parent *owner `json:"parent"` // Recursive.
+}
+
+type label struct {
+	Name string `json:"name"`
+}
+
+var exampleObject = object{ID: 1, Name: "Bike"}
+
+// GET /objects/{id} objects
+// Get an object.
+//
+// Returns a single object.
+//
+// Path: pathParams
+// Query: queryParams
+// Header: headerParams
+// Response 200: object
+// Response 200 example: $exampleObject
+// Response 404: {empty}
+
+// POST /objects objects
+// Create an object.
+//
+// Request body: object
+// Response 201: object
+
+// PUT /objects/{id} objects
+// Replace an object.
+//
+// Path: pathParams
+// Request body: object
+// Request body example: $exampleObject
+// Response 200: object
+
+// POST /objects/{id}/rename objects forms
+// Rename an object.
+//
+// Path: pathParams
+// Form: formParams
+// Response 204: {empty}
+
+// DELETE /objects/{id}
+// Delete an object.
+//
+// Deprecated: objects can't be deleted.
+// Path: pathParams
+// Cookie: cookieParams
+// Response 204: {empty}
@@ -3,10 +3,12 @@ package srvhttp // import "github.com/teamwork/kommentaar/srvhttp"
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/teamwork/kommentaar/docparse"
 	"github.com/teamwork/kommentaar/html"
@@ -22,12 +24,16 @@ type Args struct {
 	YAMLFile string
 	JSONFile string
 	HTMLFile string
+
+	// Stop scanning the packages after this long; no limit if 0. Scanning is
+	// also stopped if the client goes away.
+	Timeout time.Duration
 }
 
 // YAML outputs as OpenAPI2 YAML.
 func YAML(args Args) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
-		out, err := run(args, openapi2.WriteYAML, args.YAMLFile)
+	return func(w http.ResponseWriter, r *http.Request) {
+		out, err := run(r.Context(), args, openapi2.WriteYAML, args.YAMLFile)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, wErr := fmt.Fprintf(w, "Error: %v", err)
@@ -58,7 +64,7 @@ func JSON(args Args) http.HandlerFunc {
 			f = openapi2.WriteJSON
 		}
 
-		out, err := run(args, f, args.JSONFile)
+		out, err := run(r.Context(), args, f, args.JSONFile)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, wErr := fmt.Fprintf(w, "Error: %v", err)
@@ -78,8 +84,8 @@ func JSON(args Args) http.HandlerFunc {
 
 // HTML outputs as HTML documentation.
 func HTML(args Args) http.HandlerFunc {
-	return func(w http.ResponseWriter, _ *http.Request) {
-		out, err := run(args, html.WriteHTML, args.HTMLFile)
+	return func(w http.ResponseWriter, r *http.Request) {
+		out, err := run(r.Context(), args, html.WriteHTML, args.HTMLFile)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, wErr := fmt.Fprintf(w, "Error: %v", err)
@@ -98,6 +104,7 @@ func HTML(args Args) http.HandlerFunc {
 }
 
 func run(
+	ctx context.Context,
 	args Args,
 	out func(io.Writer, *docparse.Program) error,
 	file string,
@@ -121,8 +128,14 @@ func run(
 	}
 	prog.Config.Output = out
 
+	if args.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, args.Timeout)
+		defer cancel()
+	}
+
 	buf := bytes.NewBuffer(nil)
-	err := docparse.FindComments(buf, prog)
+	err := docparse.FindCommentsContext(ctx, buf, prog)
 	if err != nil {
 		return "", err
 	}
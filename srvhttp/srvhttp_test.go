@@ -4,7 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/teamwork/test/diff"
 )
@@ -34,6 +37,49 @@ func TestServe(t *testing.T) {
 	}
 }
 
+func TestServeTimeout(t *testing.T) {
+	args := Args{
+		Packages: []string{"../example/..."},
+		Timeout:  time.Nanosecond,
+	}
+
+	rr := httptest.NewRecorder()
+	YAML(args)(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("code %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "context deadline exceeded") {
+		t.Errorf("wrong body: %s", rr.Body.String())
+	}
+}
+
+// Run with -race to check for data races.
+func TestServeConcurrent(t *testing.T) {
+	args := Args{Packages: []string{"../example/..."}}
+	handlers := []http.HandlerFunc{YAML(args), JSON(args), HTML(args)}
+
+	want := make([]string, len(handlers))
+	for i, h := range handlers {
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		want[i] = rr.Body.String()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			handlers[i%len(handlers)](rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			if d := diff.TextDiff(want[i%len(handlers)], rr.Body.String()); d != "" {
+				t.Errorf("wrong output\n%v", d)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func TestFromFile(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/", nil)
 	args := Args{
@@ -0,0 +1,26 @@
+package deprecated
+
+type queryRef struct {
+	// Old filter {deprecated}
+	Filter string `query:"filter"`
+
+	Search string `query:"search"`
+}
+
+type object struct {
+	// Full name.
+	//
+	// Deprecated: use FirstName and LastName.
+	Name string `json:"name"`
+
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Legacy    int    `json:"legacy"` // {deprecated}
+}
+
+// GET /v2/objects tag
+// List objects.
+//
+// Deprecated: use GET /v3/objects instead.
+// Query: queryRef
+// Response 200: object
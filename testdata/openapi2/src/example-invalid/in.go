@@ -0,0 +1,13 @@
+package example
+
+type object struct {
+	Status string `json:"status"` // {enum: active inactive}
+}
+
+var exampleObject = object{Status: "deleted"}
+
+// POST /path tag
+//
+// Request body: object
+// Request body example: $exampleObject
+// Response 200: {empty}
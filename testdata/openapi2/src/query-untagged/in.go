@@ -0,0 +1,13 @@
+package query
+
+type queryRef struct {
+	Page int `query:"page"`
+
+	// Untagged, so the field name is used.
+	Sort string
+}
+
+// GET /path tag
+//
+// Query: queryRef
+// Response 200: {empty}
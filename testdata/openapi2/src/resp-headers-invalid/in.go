@@ -0,0 +1,10 @@
+package resp
+
+type createdHeaders struct {
+	Location string `header:"Location"`
+}
+
+// POST /path tag
+//
+// Response 201 headers: createdHeaders
+// Response 201: {empty}
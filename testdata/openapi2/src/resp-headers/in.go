@@ -0,0 +1,21 @@
+package resp
+
+type createdHeaders struct {
+	// URL of the created resource {required}
+	Location string `header:"Location"`
+
+	ETag string `header:"ETag"`
+
+	// Requests left in the current window.
+	RateLimitRemaining int `header:"X-RateLimit-Remaining"`
+}
+
+type object struct {
+	ID int `json:"id"`
+}
+
+// POST /path tag
+//
+// Response 201: object
+// Response 201 headers: createdHeaders
+// Response 400: {empty}
@@ -0,0 +1,6 @@
+package security
+
+// GET /path tag
+//
+// Security: basicAuth read
+// Response 200: {empty}
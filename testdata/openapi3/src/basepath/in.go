@@ -0,0 +1,6 @@
+package basepath
+
+// GET /path/{id} tag
+// Get a thing.
+//
+// Response 200: {empty}
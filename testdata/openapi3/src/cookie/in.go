@@ -0,0 +1,19 @@
+package cookie
+
+type cookieRef struct {
+	// Session ID {required}
+	Session string `cookie:"session"`
+
+	// UI theme {enum: light dark}
+	Theme string `cookie:"theme"`
+}
+
+type queryRef struct {
+	Page int `query:"page"`
+}
+
+// GET /admin tag
+//
+// Query: queryRef
+// Cookie: cookieRef
+// Response 200: {empty}
@@ -0,0 +1,37 @@
+package example
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type item struct {
+	Name string `json:"name"`
+}
+
+type object struct {
+	base
+
+	// {required}
+	Name   string  `json:"name"`
+	Status string  `json:"status"` // {enum: active inactive}
+	Score  float64 `json:"score"`
+	Items  []item  `json:"items"`
+	Secret string  `json:"-"`
+}
+
+var exampleObject = object{
+	base:   base{ID: 42},
+	Name:   "Bike",
+	Status: "active",
+	Score:  -1.5,
+	Items:  []item{{Name: "Wheel"}, {"Seat"}},
+}
+
+// POST /path tag
+//
+// Request body: object
+// Request body example: req.json
+// Response 200: object
+// Response 200 example: $exampleObject
+// Response 202: object
+// Response 202 example: resp.yaml
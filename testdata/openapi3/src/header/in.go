@@ -0,0 +1,17 @@
+package header
+
+type headerRef struct {
+	// Unique ID for this request {required}
+	RequestID string `header:"X-Request-ID"`
+
+	// Only update if the ETag matches.
+	IfMatch string `header:"If-Match"`
+
+	Count    int    `header:"X-Count"` // {range: 1-10}
+	Internal string `header:"-"`
+}
+
+// PUT /path tag
+//
+// Header: headerRef
+// Response 200: {empty}
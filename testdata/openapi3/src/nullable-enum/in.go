@@ -0,0 +1,11 @@
+package nullable
+
+type resp struct {
+	State *string `json:"state"` // {enum: open closed}
+	Kind  *string `json:"kind"`  // {enum: thing}
+	Count *int    `json:"count"` // {enum: 1 2 3}
+}
+
+// GET /path
+//
+// Response 200: resp
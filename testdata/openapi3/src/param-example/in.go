@@ -0,0 +1,27 @@
+package example
+
+type queryRef struct {
+	// Page to fetch {example: 2}
+	Page int `query:"page"`
+
+	Active bool     `query:"active" example:"true"`
+	IDs    []int64  `query:"ids" example:"1 2 3"`
+	Sort   string   `query:"sort"` // {enum: asc desc, example: desc}
+	Price  *float64 `query:"price" example:"9.95"`
+}
+
+type headerRef struct {
+	RequestID string `header:"X-Request-ID" example:"f3b1c6"`
+}
+
+type object struct {
+	Name string `json:"name" example:"Bike"`
+	Size int    `json:"size" example:"54"`
+}
+
+// POST /path tag
+//
+// Query: queryRef
+// Header: headerRef
+// Request body: object
+// Response 200: {empty}
@@ -0,0 +1,5 @@
+package path
+
+// POST /path/{companyID}/{id} tag
+//
+// Response 200: {empty}
@@ -0,0 +1,8 @@
+package req
+
+type reqRef struct{}
+
+// POST /path
+//
+// Request body: reqRef
+// Response 200: {empty}
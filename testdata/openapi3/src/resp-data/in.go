@@ -0,0 +1,6 @@
+package req
+
+// POST /path
+//
+// Response 200 (text/plain): {data}
+// Response 400: {empty}
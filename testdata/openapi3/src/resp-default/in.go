@@ -0,0 +1,7 @@
+package req
+
+type respRef struct{}
+
+// POST /path
+//
+// Response 418: {default}
@@ -0,0 +1,16 @@
+package security
+
+// GET /default tag
+//
+// Response 200: {empty}
+
+// GET /public tag
+//
+// Security: {none}
+// Response 200: {empty}
+
+// POST /oauth tag
+//
+// Security: oauth write
+// Security: basicAuth
+// Response 200: {empty}
@@ -0,0 +1,17 @@
+package path
+
+type resp struct {
+	// Items in the list.
+	Items []item          `json:"items"`
+	Main  *item           `json:"main"` // {required}
+	Extra map[string]item `json:"extra"`
+}
+
+// item comment.
+type item struct {
+	Val string `json:"val"` // {enum: a b}
+}
+
+// GET /path
+//
+// Response 200: resp
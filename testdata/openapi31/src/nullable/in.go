@@ -0,0 +1,23 @@
+package nullable
+
+type resp struct {
+	Name    *string `json:"name"`    // Name, if any.
+	Item    *item   `json:"item"`    // {required}
+	Kind    string  `json:"kind"`    // {enum: thing}
+	Count   int     `json:"count"`   // {enum: 1 2 3, default: 2}
+	Enabled *bool   `json:"enabled"` // {default: true}
+}
+
+type item struct {
+	Val string `json:"val"`
+}
+
+type queryRef struct {
+	// Page size {default: 20}.
+	PageSize *int `query:"pageSize"`
+}
+
+// GET /path
+//
+// Query: queryRef
+// Response 200: resp